@@ -1,9 +1,18 @@
 package main
 
 import (
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	gcontext "context"
@@ -11,117 +20,234 @@ import (
 	ghandlers "github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/kwabena369/scrapper/internal/config"
 	"github.com/kwabena369/scrapper/internal/db"
 	"github.com/kwabena369/scrapper/internal/email"
 	"github.com/kwabena369/scrapper/internal/handlers"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/kwabena369/scrapper/internal/jobs"
+	"github.com/kwabena369/scrapper/internal/retention"
+	"github.com/kwabena369/scrapper/internal/rss"
+	"github.com/kwabena369/scrapper/internal/scheduler"
 )
 
 func main() {
-    err := godotenv.Load()
+    // A .env file is optional; settings may come from the real environment
+    if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        log.Fatalf("Error loading .env file: %v", err)
+    }
+    cfg, args, err := config.Load(os.Args[0], os.Args[1:])
+    if errors.Is(err, flag.ErrHelp) {
+        os.Exit(0)
+    }
     if err != nil {
-        log.Fatal("Error loading .env file")
+        log.Fatalf("Invalid configuration: %v", err)
+    }
+    if len(args) > 0 && args[0] == "migrate" {
+        os.Exit(runMigrate(cfg.Storage, args[1:]))
+    }
+    if err := cfg.Validate(); err != nil {
+        log.Fatalf("Invalid configuration:\n%v", err)
+    }
+
+    // Everything that can stop the process outright is set up before the
+    // database is opened, so it is never left without being closed
+    authConfig := handlers.AuthConfig{Disabled: cfg.AuthDisabled}
+    if cfg.AuthDisabled {
+        log.Println("Authentication is disabled; every request acts as the local user")
+    } else {
+        db.ConnectFirebase(cfg.FirebaseCredPath)
+        authConfig.Client = db.AuthClient
+    }
+
+    if cfg.Email.Username != "" {
+        email.InitEmailClient(cfg.Email)
+    } else {
+        log.Println("email.user is not set; follower notifications are disabled")
     }
-    port := os.Getenv("PORT")
-    if port == "" {
-        port = "8080"
+
+    handlers.MaxConsecutiveFailures = cfg.Feeds.MaxFailures
+    handlers.DefaultPollInterval = cfg.Feeds.DefaultPollInterval
+    handlers.MinPollInterval = cfg.Feeds.MinPollInterval
+    handlers.MaxPollInterval = cfg.Feeds.MaxPollInterval
+
+    fetcher, err := rss.NewFetcher(cfg.Fetcher)
+    if err != nil {
+        log.Fatalf("Failed to configure feed fetcher: %v", err)
     }
 
-    db.ConnectMongo()
-    db.ConnectFirebase()
-    defer db.DisconnectMongo()
+    if err := run(cfg, authConfig, fetcher); err != nil {
+        log.Fatalf("Could not start server: %v", err)
+    }
+}
 
-    email.InitEmailClient()
+// run opens the storage, serves the API and runs the background work until
+// SIGINT or SIGTERM. The storage is closed on the way out unless scrapes
+// abandoned at the shutdown deadline may still be using it.
+func run(cfg config.Config, authConfig handlers.AuthConfig, fetcher *rss.Fetcher) error {
+    storage, err := openStorage(cfg.Storage)
+    if err != nil {
+        return fmt.Errorf("open storage: %w", err)
+    }
+    keepStorageOpen := false
+    defer func() {
+        if !keepStorageOpen {
+            storage.close()
+        }
+    }()
+
+    stores := storage.stores
+    queue := jobs.NewQueue(storage.jobs)
+    migrateCtx, cancelMigrate := gcontext.WithTimeout(gcontext.Background(), 5*time.Minute)
+    defer cancelMigrate()
+    if _, err := storage.migrate(migrateCtx); err != nil {
+        return fmt.Errorf("migrate database: %w", err)
+    }
 
     router := mux.NewRouter()
     router.Use(handlers.TheLoggingMiddleware)
 
+    // Runtime and scheduler metrics. They include the command line, so
+    // they are only served to signed-in users.
+    debugProtected := router.PathPrefix("/debug").Subrouter()
+    debugProtected.Use(handlers.AuthMiddleware(authConfig))
+    debugProtected.Handle("/vars", expvar.Handler()).Methods("GET")
+
     routerV1 := router.PathPrefix("/v1").Subrouter()
-    client := db.Client
 
     // Public route
-    routerV1.HandleFunc("/users", handlers.CreateUser(client)).Methods("POST")
+    routerV1.HandleFunc("/users", handlers.CreateUser(stores.Users)).Methods("POST")
 
     // Protected routes
     protected := routerV1.PathPrefix("/users").Subrouter()
-    protected.Use(handlers.AuthMiddleware)
-    protected.HandleFunc("/{id}", handlers.GetUser(client)).Methods("GET")
-    protected.HandleFunc("/{id}", handlers.UpdateUser(client)).Methods("PUT")
-    protected.HandleFunc("/{id}", handlers.DeleteUser(client)).Methods("DELETE")
+    protected.Use(handlers.AuthMiddleware(authConfig))
+    protected.HandleFunc("/{id}", handlers.GetUser(stores.Users)).Methods("GET")
+    protected.HandleFunc("/{id}", handlers.UpdateUser(stores.Users)).Methods("PUT")
+    protected.HandleFunc("/{id}", handlers.DeleteUser(stores.Users, stores.Followers)).Methods("DELETE")
 
     // Feed CRUD routes
     feedProtected := routerV1.PathPrefix("/feeds").Subrouter()
-    feedProtected.Use(handlers.AuthMiddleware)
-    feedProtected.HandleFunc("", handlers.CreateFeed(client)).Methods("POST")
-    feedProtected.HandleFunc("/{id}", handlers.GetFeed(client)).Methods("GET")
-    feedProtected.HandleFunc("/{id}", handlers.UpdateFeed(client)).Methods("PUT")
-    feedProtected.HandleFunc("/{id}", handlers.DeleteFeed(client)).Methods("DELETE")
-    feedProtected.HandleFunc("", handlers.GetAllFeeds(client)).Methods("GET")
-    feedProtected.HandleFunc("/{id}/scrape", handlers.ScrapeFeed(client)).Methods("POST")
-    feedProtected.HandleFunc("/{id}/items", handlers.GetFeedItems(client)).Methods("GET")
+    feedProtected.Use(handlers.AuthMiddleware(authConfig))
+    feedProtected.HandleFunc("", handlers.CreateFeed(stores.Feeds)).Methods("POST")
+    feedProtected.HandleFunc("/{id}", handlers.GetFeed(stores.Feeds)).Methods("GET")
+    feedProtected.HandleFunc("/{id}", handlers.UpdateFeed(stores.Feeds)).Methods("PUT")
+    feedProtected.HandleFunc("/{id}", handlers.DeleteFeed(stores.Feeds, stores.Items, stores.Followers)).Methods("DELETE")
+    feedProtected.HandleFunc("", handlers.GetAllFeeds(stores.Feeds)).Methods("GET")
+    feedProtected.HandleFunc("/{id}/scrape", handlers.ScrapeFeed(stores.Feeds, queue)).Methods("POST")
+    feedProtected.HandleFunc("/{id}/items", handlers.GetFeedItems(stores.Items)).Methods("GET")
+    feedProtected.HandleFunc("/{id}/health", handlers.GetFeedHealth(stores.Feeds)).Methods("GET")
+    feedProtected.HandleFunc("/{id}/runs", handlers.GetFeedRuns(stores.Feeds)).Methods("GET")
+
+    // Feed item routes
+    itemProtected := routerV1.PathPrefix("/items").Subrouter()
+    itemProtected.Use(handlers.AuthMiddleware(authConfig))
+    itemProtected.HandleFunc("/search", handlers.SearchItems(stores.Items)).Methods("GET")
+    itemProtected.HandleFunc("/{id}/revisions", handlers.GetItemRevisions(stores.Items)).Methods("GET")
+    itemProtected.HandleFunc("/{id}/star", handlers.SetItemStarred(stores.Items, true)).Methods("PUT")
+    itemProtected.HandleFunc("/{id}/star", handlers.SetItemStarred(stores.Items, false)).Methods("DELETE")
+
+    // Retention routes
+    retentionConfig := cfg.Retention
+    retentionProtected := routerV1.PathPrefix("/retention").Subrouter()
+    retentionProtected.Use(handlers.AuthMiddleware(authConfig))
+    retentionProtected.HandleFunc("/preview", handlers.PreviewRetention(stores.Feeds, stores.Items, retentionConfig)).Methods("GET")
+
+    // Scrape job routes
+    jobProtected := routerV1.PathPrefix("/scrape-jobs").Subrouter()
+    jobProtected.Use(handlers.AuthMiddleware(authConfig))
+    jobProtected.HandleFunc("/{id}", handlers.GetScrapeJob(queue)).Methods("GET")
 
     // FeedFollower routes
     followerProtected := routerV1.PathPrefix("/feed-followers").Subrouter()
-    followerProtected.Use(handlers.AuthMiddleware)
-    followerProtected.HandleFunc("", handlers.GetFollowedFeeds(client)).Methods("GET")
-    followerProtected.HandleFunc("", handlers.FollowFeed(client)).Methods("POST")
-    followerProtected.HandleFunc("/{id}", handlers.UnfollowFeed(client)).Methods("DELETE")
-
-    // Start cron job for periodic scraping
-    go startCronJob(client)
+    followerProtected.Use(handlers.AuthMiddleware(authConfig))
+    followerProtected.HandleFunc("", handlers.GetFollowedFeeds(stores.Followers)).Methods("GET")
+    followerProtected.HandleFunc("", handlers.FollowFeed(stores.Followers)).Methods("POST")
+    followerProtected.HandleFunc("/{id}", handlers.UnfollowFeed(stores.Followers)).Methods("DELETE")
 
     // Add CORS middleware
     corsHandler := ghandlers.CORS(
-        ghandlers.AllowedOrigins([]string{"http://localhost:3001"}),
+        ghandlers.AllowedOrigins(cfg.CORSOrigins),
         ghandlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
         ghandlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
         ghandlers.AllowCredentials(),
     )(router)
 
-    log.Printf("Starting server on port %s", port)
-    if err := http.ListenAndServe(":"+port, corsHandler); err != nil {
-        log.Fatalf("Could not start server: %s\n", err.Error())
+    // Bind the port before starting any background work, so a server that
+    // cannot listen exits straight away
+    server := &http.Server{Addr: ":" + cfg.Port, Handler: corsHandler}
+    listener, err := net.Listen("tcp", server.Addr)
+    if err != nil {
+        return fmt.Errorf("listen on %s: %w", server.Addr, err)
     }
-}
 
-func startCronJob(client *mongo.Client) {
-    ticker := time.NewTicker(1 * time.Hour) // Run every hour
-    defer ticker.Stop()
+    // ctx is cancelled on SIGINT or SIGTERM, which stops the scheduler, the
+    // janitor and the job workers from taking on new work
+    ctx, stop := signal.NotifyContext(gcontext.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    var background sync.WaitGroup
+    goBackground := func(fn func()) {
+        background.Add(1)
+        go func() {
+            defer background.Done()
+            fn()
+        }()
+    }
 
-    for range ticker.C {
-        log.Println("Running scheduled scrape job")
-        feeds, err := getAllFeeds(client)
-        if err != nil {
-            log.Printf("Failed to fetch feeds for cron job: %v", err)
-            continue
-        }
-        for _, feed := range feeds {
-            log.Printf("Scraping feed %s", feed.ID.Hex())
-            newItemsCount, _, err := handlers.ScrapeFeedLogic(client, feed.ID.Hex())
-            if err != nil {
-                log.Printf("Failed to scrape feed %s: %v", feed.ID.Hex(), err)
-                continue
-            }
-            log.Printf("Scraped feed %s, added %d new items", feed.ID.Hex(), newItemsCount)
-        }
+    // Start the scheduler for periodic scraping. Only the replica holding
+    // the scheduler lease runs it; the others take over if it goes away.
+    schedulerConfig := cfg.Scheduler
+    goBackground(func() {
+        storage.exclusive(ctx, "scheduler", schedulerConfig.LeaseTTL, scheduler.New(stores, fetcher, schedulerConfig).Run)
+    })
+
+    // Prune items outside their retention policy, on one replica at a time
+    goBackground(func() {
+        storage.exclusive(ctx, "retention", retentionConfig.LeaseTTL, retention.NewJanitor(stores.Feeds, stores.Items, retentionConfig).Run)
+    })
+
+    // Work through queued manual scrapes
+    scrape := func(ctx gcontext.Context, feedID string) (int, error) {
+        newItemsCount, _, err := handlers.ScrapeFeedLogic(ctx, stores, fetcher, feedID)
+        return newItemsCount, err
     }
-}
+    goBackground(func() {
+        queue.Process(ctx, cfg.Jobs, scrape, handlers.ScrapeErrorCode)
+    })
+
+    log.Printf("Starting server on port %s", cfg.Port)
+    go func() {
+        if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            log.Printf("Server stopped: %v", err)
+            stop()
+        }
+    }()
 
-func getAllFeeds(client *mongo.Client) ([]handlers.Feed, error) {
-    collection := client.Database("hope").Collection("feeds")
-    ctx, cancel := gcontext.WithTimeout(gcontext.Background(), 5*time.Second)
-    defer cancel()
+    <-ctx.Done()
+    stop()
+    log.Printf("Shutting down, waiting up to %v for work in progress", cfg.ShutdownTimeout)
+    shutdownCtx, cancelShutdown := gcontext.WithTimeout(gcontext.Background(), cfg.ShutdownTimeout)
+    defer cancelShutdown()
 
-    cursor, err := collection.Find(ctx, bson.M{})
-    if err != nil {
-        return nil, err
+    // Stop accepting requests and let the ones in flight finish
+    if err := server.Shutdown(shutdownCtx); err != nil {
+        log.Printf("Failed to finish in-flight requests: %v", err)
     }
-    defer cursor.Close(ctx)
 
-    var feeds []handlers.Feed
-    if err = cursor.All(ctx, &feeds); err != nil {
-        return nil, err
+    // Scrapes already started run to completion; their follower emails are
+    // sent once no more scrapes can start
+    drained := make(chan struct{})
+    go func() {
+        background.Wait()
+        handlers.WaitForNotifications()
+        close(drained)
+    }()
+    select {
+    case <-drained:
+        log.Println("Shutdown complete")
+    case <-shutdownCtx.Done():
+        // The abandoned scrapes may still write, so the storage is left for
+        // the process exit to close
+        log.Println("Shutdown deadline passed, abandoning unfinished scrapes and notifications")
+        keepStorageOpen = true
     }
-    return feeds, nil
-}
\ No newline at end of file
+    return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	gcontext "context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/config"
+)
+
+// runMigrate handles "api migrate [up|status]", applying or listing schema
+// migrations without starting the server. It returns the exit code.
+func runMigrate(cfg config.Storage, args []string) int {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if command != "up" && command != "status" {
+		fmt.Fprintf(os.Stderr, "usage: %s migrate [up|status]\n", os.Args[0])
+		return 2
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	storage, err := openStorage(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open storage: %v\n", err)
+		return 1
+	}
+	defer storage.close()
+
+	ctx, cancel := gcontext.WithTimeout(gcontext.Background(), 10*time.Minute)
+	defer cancel()
+
+	if command == "up" {
+		ran, err := storage.migrate(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Applied %d migration(s)\n", ran)
+		return 0
+	}
+
+	statuses, err := storage.status(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migration status: %v\n", err)
+		return 1
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "VERSION\tAPPLIED\tDESCRIPTION")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(out, "%d\t%s\t%s\n", status.Version, applied, status.Description)
+	}
+	out.Flush()
+	return 0
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	gcontext "context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/config"
+	"github.com/kwabena369/scrapper/internal/db"
+	"github.com/kwabena369/scrapper/internal/jobs"
+	"github.com/kwabena369/scrapper/internal/lease"
+	"github.com/kwabena369/scrapper/internal/sqlstore"
+	"github.com/kwabena369/scrapper/internal/store"
+)
+
+// storage is the database the server keeps its data in, chosen by the
+// storage driver setting
+type storage struct {
+	stores store.Stores
+	jobs   jobs.Store
+	// migrate applies the pending schema migrations and returns how many ran
+	migrate func(ctx gcontext.Context) (int, error)
+	status  func(ctx gcontext.Context) ([]migrationStatus, error)
+	// exclusive runs fn on one instance at a time until ctx is done
+	exclusive func(ctx gcontext.Context, name string, ttl time.Duration, fn func(gcontext.Context))
+	close     func()
+}
+
+// migrationStatus is a migration as reported by "migrate status"
+type migrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   *time.Time
+}
+
+// openStorage connects to the database selected by cfg: "mongo",
+// "postgres" or "sqlite". cfg must have been validated.
+func openStorage(cfg config.Storage) (*storage, error) {
+	switch cfg.Driver {
+	case "mongo":
+		return openMongoStorage(cfg.MongoURI, cfg.MongoDatabase), nil
+	case "sqlite":
+		return openSQLiteStorage(cfg.SQLitePath)
+	case "postgres":
+		return openPostgresStorage(cfg.PostgresURL)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+func openMongoStorage(uri, name string) *storage {
+	db.ConnectMongo(uri)
+	database := db.Client.Database(name)
+	jobStore := jobs.NewMongoStore(database)
+	return &storage{
+		stores: db.NewStores(database),
+		jobs:   jobStore,
+		migrate: func(ctx gcontext.Context) (int, error) {
+			ran, err := db.Migrate(ctx, database)
+			if err != nil {
+				return len(ran), err
+			}
+			if err := jobStore.EnsureIndexes(ctx); err != nil {
+				return len(ran), fmt.Errorf("create scrape job indexes: %w", err)
+			}
+			return len(ran), nil
+		},
+		status: func(ctx gcontext.Context) ([]migrationStatus, error) {
+			statuses, err := db.Status(ctx, database)
+			if err != nil {
+				return nil, err
+			}
+			var reported []migrationStatus
+			for _, status := range statuses {
+				entry := migrationStatus{Version: status.Version, Description: status.Description}
+				if status.Applied != nil {
+					entry.AppliedAt = &status.Applied.AppliedAt
+				}
+				reported = append(reported, entry)
+			}
+			return reported, nil
+		},
+		// Replicas share the database, so they take turns through a lease
+		exclusive: func(ctx gcontext.Context, name string, ttl time.Duration, fn func(gcontext.Context)) {
+			db.NewLease(database, name, ttl).RunWhileLeader(ctx, fn)
+		},
+		close: db.DisconnectMongo,
+	}
+}
+
+func openSQLiteStorage(path string) (*storage, error) {
+	database, err := sqlstore.OpenSQLite(path)
+	if err != nil {
+		return nil, fmt.Errorf("open SQLite database %s: %w", path, err)
+	}
+	log.Printf("Using SQLite database %s", path)
+	s := sqlStorage(database)
+	// A SQLite file serves a single instance, which always runs the job
+	s.exclusive = func(ctx gcontext.Context, name string, ttl time.Duration, fn func(gcontext.Context)) {
+		fn(ctx)
+	}
+	return s, nil
+}
+
+func openPostgresStorage(url string) (*storage, error) {
+	database, err := sqlstore.OpenPostgres(url)
+	if err != nil {
+		return nil, fmt.Errorf("connect to PostgreSQL: %w", err)
+	}
+	log.Println("Connected to PostgreSQL successfully")
+	s := sqlStorage(database)
+	s.exclusive = func(ctx gcontext.Context, name string, ttl time.Duration, fn func(gcontext.Context)) {
+		lease.RunWhileLeader(ctx, database.NewLease(name, ttl), fn)
+	}
+	return s, nil
+}
+
+// sqlStorage is what the SQL databases have in common; the caller decides
+// how instances share work
+func sqlStorage(database *sqlstore.DB) *storage {
+	return &storage{
+		stores: database.Stores(),
+		jobs:   database.Jobs(),
+		migrate: func(ctx gcontext.Context) (int, error) {
+			ran, err := database.Migrate(ctx)
+			return len(ran), err
+		},
+		status: func(ctx gcontext.Context) ([]migrationStatus, error) {
+			statuses, err := database.Status(ctx)
+			if err != nil {
+				return nil, err
+			}
+			var reported []migrationStatus
+			for _, status := range statuses {
+				entry := migrationStatus{Version: status.Version, Description: status.Description}
+				if status.Applied != nil {
+					entry.AppliedAt = &status.Applied.AppliedAt
+				}
+				reported = append(reported, entry)
+			}
+			return reported, nil
+		},
+		close: func() {
+			if err := database.Close(); err != nil {
+				log.Printf("Failed to close database: %v", err)
+			}
+		},
+	}
+}
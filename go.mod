@@ -4,12 +4,16 @@ go 1.24.3
 
 require (
 	firebase.google.com/go/v4 v4.15.2
+	github.com/BurntSushi/toml v1.6.0
 	github.com/gorilla/handlers v1.5.2
 	github.com/gorilla/mux v1.8.1
 	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.33
 	go.mongodb.org/mongo-driver v1.17.3
 	google.golang.org/api v0.234.0
 	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
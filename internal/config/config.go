@@ -0,0 +1,167 @@
+// Package config holds the server's settings. They are loaded once at boot
+// from defaults, an optional YAML or TOML file, environment variables and
+// command-line flags, in increasing order of precedence, and validated
+// before anything is started.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/email"
+	"github.com/kwabena369/scrapper/internal/jobs"
+	"github.com/kwabena369/scrapper/internal/retention"
+	"github.com/kwabena369/scrapper/internal/rss"
+	"github.com/kwabena369/scrapper/internal/scheduler"
+)
+
+// Config is every setting of the server
+type Config struct {
+	Port            string
+	CORSOrigins     []string      // origins allowed to call the API from a browser
+	ShutdownTimeout time.Duration // how long shutdown waits for requests and scrapes to finish
+
+	Storage          Storage
+	FirebaseCredPath string
+	AuthDisabled     bool         // serve every route without sign-in, for single-user setups
+	Email            email.Config // notifications are only sent when Username is set
+	Feeds            Feeds
+	Fetcher          rss.FetcherConfig
+	Scheduler        scheduler.Config
+	Jobs             jobs.Config
+	Retention        retention.Config
+}
+
+// Storage selects the database and how to reach it
+type Storage struct {
+	Driver        string // "mongo", "postgres" or "sqlite"
+	MongoURI      string
+	MongoDatabase string
+	PostgresURL   string
+	SQLitePath    string
+}
+
+// Feeds bounds how often feeds are polled and when failing ones are paused
+type Feeds struct {
+	MaxFailures         int // zero never pauses a feed
+	DefaultPollInterval time.Duration
+	MinPollInterval     time.Duration
+	MaxPollInterval     time.Duration
+}
+
+// Default returns the settings used when nothing is configured
+func Default() Config {
+	return Config{
+		Port:            "8080",
+		CORSOrigins:     []string{"http://localhost:3001"},
+		ShutdownTimeout: 30 * time.Second,
+		Storage: Storage{
+			Driver:        "mongo",
+			MongoDatabase: "hope",
+			SQLitePath:    "scrapper.db",
+		},
+		Email: email.DefaultConfig(),
+		Feeds: Feeds{
+			MaxFailures:         10,
+			DefaultPollInterval: time.Hour,
+			MinPollInterval:     5 * time.Minute,
+			MaxPollInterval:     24 * time.Hour,
+		},
+		Fetcher:   rss.DefaultFetcherConfig(),
+		Scheduler: scheduler.DefaultConfig(),
+		Jobs:      jobs.DefaultConfig(),
+		Retention: retention.DefaultConfig(),
+	}
+}
+
+// Validate reports every setting the server cannot start with
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port < 65536, "port %q is not a TCP port", c.Port)
+	for _, origin := range c.CORSOrigins {
+		check(origin == "*" || isHTTPURL(origin), "CORS origin %q is not an http(s) URL or *", origin)
+	}
+	check(c.ShutdownTimeout > 0, "shutdown_timeout must be positive")
+
+	if err := c.Storage.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	check(c.FirebaseCredPath != "" || c.AuthDisabled, "firebase.cred_path is required unless auth.disabled is set")
+	check(c.FirebaseCredPath == "" || !c.AuthDisabled, "firebase.cred_path and auth.disabled are mutually exclusive")
+
+	if c.Email.Username != "" || c.Email.Password != "" {
+		check(c.Email.Host != "", "email.host is required to send notifications")
+		check(c.Email.Port > 0 && c.Email.Port < 65536, "email.port %d is not a TCP port", c.Email.Port)
+		check(c.Email.Username != "" && c.Email.Password != "", "email.user and email.pass must be set together")
+	}
+
+	check(c.Feeds.MaxFailures >= 0, "feed.max_failures must not be negative")
+	check(c.Feeds.MinPollInterval > 0, "feed.min_poll_interval must be positive")
+	check(c.Feeds.MinPollInterval <= c.Feeds.DefaultPollInterval && c.Feeds.DefaultPollInterval <= c.Feeds.MaxPollInterval,
+		"feed poll intervals must satisfy min (%v) <= default (%v) <= max (%v)",
+		c.Feeds.MinPollInterval, c.Feeds.DefaultPollInterval, c.Feeds.MaxPollInterval)
+
+	check(c.Fetcher.ConnectTimeout > 0, "fetch.connect_timeout must be positive")
+	check(c.Fetcher.ReadTimeout > 0, "fetch.read_timeout must be positive")
+	check(c.Fetcher.MaxBytes > 0, "fetch.max_bytes must be positive")
+	check(c.Fetcher.MaxRedirects >= 0, "fetch.max_redirects must not be negative")
+	check(c.Fetcher.HostConcurrency >= 0, "fetch.host_concurrency must not be negative")
+	check(c.Fetcher.HostDelay >= 0, "fetch.host_delay must not be negative")
+	check(c.Fetcher.MaxRetryAfter >= 0, "fetch.max_retry_after must not be negative")
+	check(c.Fetcher.RetryAfter >= 0, "fetch.retry_after must not be negative")
+	if c.Fetcher.ProxyURL != "" {
+		_, err := url.Parse(c.Fetcher.ProxyURL)
+		check(err == nil, "fetch.proxy %q is not a URL", c.Fetcher.ProxyURL)
+	}
+
+	check(c.Scheduler.Concurrency > 0, "scheduler.concurrency must be positive")
+	check(c.Scheduler.RunTimeout > 0, "scheduler.run_timeout must be positive")
+	check(c.Scheduler.LeaseTTL > 0, "scheduler.lease_ttl must be positive")
+
+	check(c.Jobs.Workers > 0, "jobs.workers must be positive")
+	check(c.Jobs.JobTimeout > 0, "jobs.timeout must be positive")
+
+	check(c.Retention.Default.MaxAge >= 0, "retention.max_age must not be negative")
+	check(c.Retention.Default.MaxItems >= 0, "retention.max_items must not be negative")
+	check(c.Retention.Interval > 0, "retention.interval must be positive")
+	check(c.Retention.LeaseTTL > 0, "retention.lease_ttl must be positive")
+
+	return errors.Join(errs...)
+}
+
+// Validate reports whether the selected database can be reached with the
+// given settings. It is all the migrate command needs.
+func (s Storage) Validate() error {
+	switch s.Driver {
+	case "mongo":
+		if s.MongoURI == "" || s.MongoDatabase == "" {
+			return errors.New("mongo.uri and mongo.database are required with the mongo storage driver")
+		}
+	case "postgres":
+		if s.PostgresURL == "" {
+			return errors.New("postgres.url is required with the postgres storage driver")
+		}
+	case "sqlite":
+		if s.SQLitePath == "" {
+			return errors.New("sqlite.path is required with the sqlite storage driver")
+		}
+	default:
+		return fmt.Errorf("unknown storage driver %q, want mongo, postgres or sqlite", s.Driver)
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
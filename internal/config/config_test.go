@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Default()
+	valid.Storage.MongoURI = "mongodb://localhost:27017"
+	valid.FirebaseCredPath = "firebase.json"
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("defaults with a database and Firebase rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		ok     bool
+	}{
+		{"auth disabled instead of Firebase", func(c *Config) { c.FirebaseCredPath = ""; c.AuthDisabled = true }, true},
+		{"neither Firebase nor auth disabled", func(c *Config) { c.FirebaseCredPath = "" }, false},
+		{"Firebase and auth disabled", func(c *Config) { c.AuthDisabled = true }, false},
+		{"email credentials", func(c *Config) { c.Email.Username = "me@example.com"; c.Email.Password = "secret" }, true},
+		{"email user without password", func(c *Config) { c.Email.Username = "me@example.com" }, false},
+		{"sqlite", func(c *Config) { c.Storage.Driver = "sqlite" }, true},
+		{"unknown driver", func(c *Config) { c.Storage.Driver = "redis" }, false},
+		{"bad port", func(c *Config) { c.Port = "http" }, false},
+		{"bad CORS origin", func(c *Config) { c.CORSOrigins = []string{"example.com"} }, false},
+		{"default poll interval below the minimum", func(c *Config) { c.Feeds.DefaultPollInterval = time.Minute }, false},
+		{"negative retention age", func(c *Config) { c.Retention.Default.MaxAge = -time.Hour }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			if err := cfg.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from the defaults, the file named by the
+// -config flag or CONFIG_FILE, the environment and the flags in args, each
+// overriding the ones before. Every setting has a dotted file key such as
+// "scheduler.lease_ttl", which is also available as the environment
+// variable SCHEDULER_LEASE_TTL and the flag -scheduler-lease-ttl. An
+// empty environment variable counts as unset, as .env files often leave
+// them blank.
+//
+// It returns the arguments left after the flags. The configuration is not
+// validated; callers check what they need.
+func Load(name string, args []string) (Config, []string, error) {
+	cfg := Default()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", "", "YAML or TOML configuration file (default $CONFIG_FILE)")
+	settings := bindSettings(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	// Flags win, so remember which were given before applying the rest
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return cfg, nil, err
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			flagName, ok := settings[key]
+			if !ok {
+				return cfg, nil, fmt.Errorf("%s: unknown setting %q", path, key)
+			}
+			if given[flagName] {
+				continue
+			}
+			if err := fs.Set(flagName, values[key]); err != nil {
+				return cfg, nil, fmt.Errorf("%s: invalid value %q for %s: %v", path, values[key], key, err)
+			}
+		}
+	}
+
+	for key, flagName := range settings {
+		value := os.Getenv(envName(key))
+		if value == "" || given[flagName] {
+			continue
+		}
+		if err := fs.Set(flagName, value); err != nil {
+			return cfg, nil, fmt.Errorf("invalid value %q for %s: %v", value, envName(key), err)
+		}
+	}
+	return cfg, fs.Args(), nil
+}
+
+// bindSettings registers a flag for every setting, bound to its field of
+// cfg, and returns the flag name of each file key
+func bindSettings(fs *flag.FlagSet, cfg *Config) map[string]string {
+	b := binder{fs: fs, flags: map[string]string{}}
+
+	b.string("port", &cfg.Port, "HTTP listen port")
+	b.list("cors.origins", &cfg.CORSOrigins, "comma-separated origins allowed by CORS, or *")
+	b.duration("shutdown_timeout", &cfg.ShutdownTimeout, "how long shutdown waits for requests, scrapes and notifications to finish")
+
+	b.string("storage.driver", &cfg.Storage.Driver, "database: mongo, postgres or sqlite")
+	b.string("mongo.uri", &cfg.Storage.MongoURI, "MongoDB connection URI")
+	b.string("mongo.database", &cfg.Storage.MongoDatabase, "MongoDB database name")
+	b.string("postgres.url", &cfg.Storage.PostgresURL, "PostgreSQL connection URL")
+	b.string("sqlite.path", &cfg.Storage.SQLitePath, "SQLite database file")
+
+	b.string("firebase.cred_path", &cfg.FirebaseCredPath, "Firebase service account credentials file")
+	b.bool("auth.disabled", &cfg.AuthDisabled, "serve every route without sign-in instead of using Firebase; for single-user setups")
+
+	b.string("email.host", &cfg.Email.Host, "SMTP server host")
+	b.int("email.port", &cfg.Email.Port, "SMTP server port")
+	b.string("email.user", &cfg.Email.Username, "SMTP username and sender address; unset disables notifications")
+	b.string("email.pass", &cfg.Email.Password, "SMTP password")
+
+	b.int("feed.max_failures", &cfg.Feeds.MaxFailures, "consecutive failed fetches before a feed is paused; 0 never pauses")
+	b.duration("feed.default_poll_interval", &cfg.Feeds.DefaultPollInterval, "poll interval of feeds that set none")
+	b.duration("feed.min_poll_interval", &cfg.Feeds.MinPollInterval, "shortest poll interval allowed")
+	b.duration("feed.max_poll_interval", &cfg.Feeds.MaxPollInterval, "longest poll interval allowed")
+
+	b.duration("fetch.connect_timeout", &cfg.Fetcher.ConnectTimeout, "deadline for dialing and the TLS handshake")
+	b.duration("fetch.read_timeout", &cfg.Fetcher.ReadTimeout, "deadline for each feed request, including reading its body")
+	b.int64("fetch.max_bytes", &cfg.Fetcher.MaxBytes, "largest decoded feed body accepted")
+	b.string("fetch.user_agent", &cfg.Fetcher.UserAgent, "User-Agent sent with feed requests")
+	b.int("fetch.max_redirects", &cfg.Fetcher.MaxRedirects, "redirects followed per feed request")
+	b.string("fetch.proxy", &cfg.Fetcher.ProxyURL, "proxy URL for feed requests (default from HTTP_PROXY/HTTPS_PROXY)")
+	b.int("fetch.host_concurrency", &cfg.Fetcher.HostConcurrency, "feed requests in flight per host; 0 is unlimited")
+	b.duration("fetch.host_delay", &cfg.Fetcher.HostDelay, "minimum gap between requests to a host")
+	b.duration("fetch.max_retry_after", &cfg.Fetcher.MaxRetryAfter, "longest Retry-After honoured")
+	b.duration("fetch.retry_after", &cfg.Fetcher.RetryAfter, "back-off from a host that answers 429 or 503 without Retry-After")
+
+	b.int("scheduler.concurrency", &cfg.Scheduler.Concurrency, "feeds scraped in parallel")
+	b.duration("scheduler.run_timeout", &cfg.Scheduler.RunTimeout, "deadline for scraping one feed")
+	b.duration("scheduler.lease_ttl", &cfg.Scheduler.LeaseTTL, "how long the scheduler lease outlives its last renewal")
+
+	b.int("jobs.workers", &cfg.Jobs.Workers, "scrape jobs processed in parallel")
+	b.duration("jobs.timeout", &cfg.Jobs.JobTimeout, "deadline for one scrape job")
+
+	b.duration("retention.max_age", &cfg.Retention.Default.MaxAge, "default age after which items are pruned; 0 keeps them")
+	b.int("retention.max_items", &cfg.Retention.Default.MaxItems, "default number of newest items kept per feed; 0 keeps all")
+	b.duration("retention.interval", &cfg.Retention.Interval, "time between pruning passes")
+	b.duration("retention.lease_ttl", &cfg.Retention.LeaseTTL, "how long the janitor lease outlives its last renewal")
+
+	return b.flags
+}
+
+// binder registers settings as flags named after their file keys
+type binder struct {
+	fs    *flag.FlagSet
+	flags map[string]string
+}
+
+func (b binder) name(key string) string {
+	name := strings.NewReplacer(".", "-", "_", "-").Replace(key)
+	b.flags[key] = name
+	return name
+}
+
+func (b binder) usage(key, usage string) string {
+	return fmt.Sprintf("%s ($%s)", usage, envName(key))
+}
+
+func (b binder) string(key string, p *string, usage string) {
+	b.fs.StringVar(p, b.name(key), *p, b.usage(key, usage))
+}
+
+func (b binder) bool(key string, p *bool, usage string) {
+	b.fs.BoolVar(p, b.name(key), *p, b.usage(key, usage))
+}
+
+func (b binder) int(key string, p *int, usage string) {
+	b.fs.IntVar(p, b.name(key), *p, b.usage(key, usage))
+}
+
+func (b binder) int64(key string, p *int64, usage string) {
+	b.fs.Int64Var(p, b.name(key), *p, b.usage(key, usage))
+}
+
+func (b binder) duration(key string, p *time.Duration, usage string) {
+	b.fs.DurationVar(p, b.name(key), *p, b.usage(key, usage))
+}
+
+func (b binder) list(key string, p *[]string, usage string) {
+	b.fs.Var((*listValue)(p), b.name(key), b.usage(key, usage))
+}
+
+// listValue is a comma-separated list flag; setting it replaces the list
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = nil
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*l = append(*l, part)
+		}
+	}
+	return nil
+}
+
+// envName is the environment variable of a file key
+func envName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// readFile reads a YAML or TOML file, chosen by its extension, into
+// setting values keyed by their dotted file keys
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var tree map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	flatten("", tree, values)
+	return values, nil
+}
+
+// flatten turns nested tables into dotted keys. Lists become
+// comma-separated values.
+func flatten(prefix string, tree map[string]interface{}, values map[string]string) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case nil:
+			// An empty entry leaves the setting alone
+		case map[string]interface{}:
+			flatten(key, value, values)
+		case []interface{}:
+			parts := make([]string, 0, len(value))
+			for _, part := range value {
+				parts = append(parts, fmt.Sprint(part))
+			}
+			values[key] = strings.Join(parts, ",")
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+port: 9000
+scheduler:
+  concurrency: 8
+  lease_ttl: 1m
+cors:
+  origins: [http://a.example, http://b.example]
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("SCHEDULER_CONCURRENCY", "12")
+	t.Setenv("JOBS_WORKERS", "3")
+
+	cfg, args, err := Load("test", []string{"-scheduler-concurrency", "16", "migrate", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args, []string{"migrate", "up"}) {
+		t.Errorf("args = %q, want the ones after the flags", args)
+	}
+	if cfg.Port != "9000" || cfg.Scheduler.LeaseTTL != time.Minute {
+		t.Errorf("file settings not applied: port %q, lease TTL %v", cfg.Port, cfg.Scheduler.LeaseTTL)
+	}
+	if !reflect.DeepEqual(cfg.CORSOrigins, []string{"http://a.example", "http://b.example"}) {
+		t.Errorf("CORS origins = %q", cfg.CORSOrigins)
+	}
+	if cfg.Jobs.Workers != 3 {
+		t.Errorf("jobs workers = %d, want 3 from the environment", cfg.Jobs.Workers)
+	}
+	if cfg.Scheduler.Concurrency != 16 {
+		t.Errorf("scheduler concurrency = %d, want 16 from the flag", cfg.Scheduler.Concurrency)
+	}
+}
+
+func TestLoadSkipsEmptyEnvironmentVariables(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("RETENTION_MAX_AGE", "")
+	t.Setenv("FETCH_USER_AGENT", "")
+	t.Setenv("PORT", "")
+
+	cfg, _, err := Load("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	if cfg.Retention.Default.MaxAge != want.Retention.Default.MaxAge {
+		t.Errorf("retention max age = %v, want the default %v", cfg.Retention.Default.MaxAge, want.Retention.Default.MaxAge)
+	}
+	if cfg.Fetcher.UserAgent != want.Fetcher.UserAgent {
+		t.Errorf("user agent = %q, want the default %q", cfg.Fetcher.UserAgent, want.Fetcher.UserAgent)
+	}
+	if cfg.Port != want.Port {
+		t.Errorf("port = %q, want the default %q", cfg.Port, want.Port)
+	}
+}
+
+func TestLoadRejectsBadSettings(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+	}{
+		{"unknown file key", "scheduler:\n  concurency: 4\n", nil},
+		{"invalid file value", "jobs:\n  workers: many\n", nil},
+		{"invalid environment value", "", map[string]string{"RETENTION_MAX_AGE": "a week"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []string
+			if tt.file != "" {
+				args = []string{"-config", writeFile(t, "config.yaml", tt.file)}
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if _, _, err := Load("test", args); err == nil {
+				t.Fatal("Load accepted a bad setting")
+			}
+		})
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[retention]
+max_age = "168h"
+max_items = 50
+`)
+	cfg, _, err := Load("test", []string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Retention.Default.MaxAge != 168*time.Hour || cfg.Retention.Default.MaxItems != 50 {
+		t.Errorf("retention = %+v", cfg.Retention.Default)
+	}
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+
+	"github.com/kwabena369/scrapper/internal/rss"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// backfillIdentityKeys gives items stored before identity keys existed the
+// key they would get today. Items that would collide with one already stored
+// get a key derived from their own ID instead, so nothing is deleted.
+func backfillIdentityKeys(ctx context.Context, items *mongo.Collection) error {
+	cursor, err := items.Find(ctx, bson.M{"identity_key": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item struct {
+			ID          primitive.ObjectID `bson:"_id"`
+			FeedID      primitive.ObjectID `bson:"feed_id"`
+			GUID        string             `bson:"guid"`
+			Title       string             `bson:"title"`
+			Link        string             `bson:"link"`
+			Description string             `bson:"description"`
+		}
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+
+		key := rss.IdentityKey(item.GUID, item.Link, item.Title, item.Description)
+		taken, err := items.CountDocuments(ctx, bson.M{"feed_id": item.FeedID, "identity_key": key})
+		if err != nil {
+			return err
+		}
+		if taken > 0 {
+			key = "legacy:" + item.ID.Hex()
+		}
+
+		_, err = items.UpdateOne(ctx, bson.M{"_id": item.ID}, bson.M{"$set": bson.M{"identity_key": key}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// rekeyFragmentLinks gives items keyed by a link with a fragment the key
+// they get today. Links used to be keyed without their fragment, so only
+// the first of several items linking to anchors of one page was stored.
+func rekeyFragmentLinks(ctx context.Context, items *mongo.Collection) error {
+	cursor, err := items.Find(ctx, bson.M{
+		"identity_key": bson.M{"$regex": "^link:"},
+		"link":         bson.M{"$regex": "#"},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item struct {
+			ID          primitive.ObjectID `bson:"_id"`
+			Link        string             `bson:"link"`
+			IdentityKey string             `bson:"identity_key"`
+		}
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+		key := rss.IdentityKey("", item.Link, "", "")
+		if key == item.IdentityKey {
+			continue
+		}
+		_, err = items.UpdateOne(ctx, bson.M{"_id": item.ID}, bson.M{"$set": bson.M{"identity_key": key}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// dedupeFollowers keeps the earliest of each user's subscriptions to a feed,
+// so the pair can be indexed uniquely. Duplicates were only ever left behind
+// by concurrent follows of the same feed.
+func dedupeFollowers(ctx context.Context, followers *mongo.Collection) error {
+	cursor, err := followers.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"feed_id": "$feed_id", "user_id": "$user_id"},
+			"ids": bson.M{"$push": "$_id"},
+		}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var group struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return err
+		}
+		if _, err := followers.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
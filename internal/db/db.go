@@ -3,7 +3,6 @@ package db
 import (
 	"context"
 	"log"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -22,12 +21,7 @@ type UserClaims struct {
 	Email string
 }
 
-func ConnectMongo() {
-	uri := os.Getenv("MONGO_URI")
-	if uri == "" {
-		log.Fatal("MONGO_URI not set in .env")
-	}
-
+func ConnectMongo(uri string) {
 	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
 	if err != nil {
 		log.Fatalf("Failed to connect to MongoDB: %v", err)
@@ -45,14 +39,9 @@ func ConnectMongo() {
 	log.Println("Connected to MongoDB successfully")
 }
 
-func ConnectFirebase() {
+func ConnectFirebase(credPath string) {
 	ctx := context.Background()
 
-	credPath := os.Getenv("FIREBASE_CRED_PATH")
-	if credPath == "" {
-		log.Fatal("FIREBASE_CRED_PATH not set in .env")
-	}
-
 	absPath, err := filepath.Abs(credPath)
 	if err != nil {
 		log.Fatalf("Failed to resolve Firebase credentials path: %v", err)
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FeedStore is the MongoDB store.FeedStore, backed by "feeds" and
+// "scrape_runs"
+type FeedStore struct {
+	collection *mongo.Collection
+	runs       *mongo.Collection
+}
+
+func (s *FeedStore) Create(ctx context.Context, feed models.Feed) error {
+	_, err := s.collection.InsertOne(ctx, feed)
+	return err
+}
+
+func (s *FeedStore) Get(ctx context.Context, id primitive.ObjectID) (models.Feed, error) {
+	var feed models.Feed
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&feed)
+	return feed, notFound(err)
+}
+
+func (s *FeedStore) Update(ctx context.Context, id primitive.ObjectID, settings store.FeedSettings) error {
+	set := bson.M{
+		"name":       settings.Name,
+		"url":        settings.Url,
+		"user_id":    settings.UserID,
+		"updated_at": settings.UpdatedAt,
+	}
+	unset := bson.M{}
+	if settings.PollInterval != 0 {
+		set["poll_interval"] = settings.PollInterval
+	} else {
+		unset["poll_interval"] = ""
+	}
+	if settings.Retention != nil {
+		set["retention"] = settings.Retention
+	} else {
+		unset["retention"] = ""
+	}
+	if settings.NextFetchAt != nil {
+		set["next_fetch_at"] = *settings.NextFetchAt
+	}
+	if settings.ResetFetchState {
+		for _, field := range []string{
+			"hints", "etag", "last_modified", "last_attempt_at", "last_success_at", "last_http_status",
+			"consecutive_failures", "last_error", "last_error_code", "last_error_at", "paused", "paused_at",
+		} {
+			unset[field] = ""
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+func (s *FeedStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (s *FeedStore) List(ctx context.Context, filter store.FeedFilter) ([]models.Feed, error) {
+	query := bson.M{}
+	switch filter.Health {
+	case store.HealthAny:
+	case store.HealthHealthy:
+		query["consecutive_failures"] = bson.M{"$in": bson.A{nil, 0}}
+		query["paused"] = bson.M{"$ne": true}
+	case store.HealthUnhealthy:
+		query["$or"] = bson.A{
+			bson.M{"consecutive_failures": bson.M{"$gt": 0}},
+			bson.M{"paused": true},
+		}
+	case store.HealthPaused:
+		query["paused"] = true
+	default:
+		return nil, fmt.Errorf("unknown health filter %q", filter.Health)
+	}
+	return s.find(ctx, query, options.Find())
+}
+
+func (s *FeedStore) RecordFetchSuccess(ctx context.Context, id primitive.ObjectID, success store.FetchSuccess) error {
+	set := bson.M{
+		"etag":             success.ETag,
+		"last_modified":    success.LastModified,
+		"last_attempt_at":  success.At,
+		"last_success_at":  success.At,
+		"last_http_status": success.HTTPStatus,
+	}
+	if success.Hints != nil {
+		set["hints"] = success.Hints
+	}
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": set,
+		"$unset": bson.M{
+			"consecutive_failures": "",
+			"last_error":           "",
+			"last_error_code":      "",
+			"last_error_at":        "",
+			"paused":               "",
+			"paused_at":            "",
+		},
+	})
+	return err
+}
+
+func (s *FeedStore) RecordFetchFailure(ctx context.Context, id primitive.ObjectID, failure store.FetchFailure) (models.Feed, error) {
+	set := bson.M{
+		"last_attempt_at": failure.At,
+		"last_error":      failure.Error,
+		"last_error_code": failure.ErrorCode,
+		"last_error_at":   failure.At,
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"consecutive_failures": 1}}
+	if failure.HTTPStatus != 0 {
+		set["last_http_status"] = failure.HTTPStatus
+	} else {
+		update["$unset"] = bson.M{"last_http_status": ""}
+	}
+
+	var feed models.Feed
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&feed)
+	return feed, notFound(err)
+}
+
+func (s *FeedStore) Pause(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"paused":    true,
+		"paused_at": at,
+	}})
+	return err
+}
+
+func (s *FeedStore) Due(ctx context.Context, now time.Time) ([]models.Feed, error) {
+	// Paused feeds have failed too often and are only retried manually
+	return s.find(ctx, bson.M{
+		"paused": bson.M{"$ne": true},
+		"$or": bson.A{
+			bson.M{"next_fetch_at": nil},
+			bson.M{"next_fetch_at": bson.M{"$lte": now}},
+		},
+	}, options.Find().SetSort(bson.M{"next_fetch_at": 1}))
+}
+
+func (s *FeedStore) NextDue(ctx context.Context) (*time.Time, error) {
+	var next models.Feed
+	opts := options.FindOne().SetSort(bson.M{"next_fetch_at": 1})
+	err := s.collection.FindOne(ctx, bson.M{
+		"paused":        bson.M{"$ne": true},
+		"next_fetch_at": bson.M{"$ne": nil},
+	}, opts).Decode(&next)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return next.NextFetchAt, err
+}
+
+func (s *FeedStore) Reschedule(ctx context.Context, id primitive.ObjectID, interval int, next time.Time) error {
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"current_interval": interval,
+		"next_fetch_at":    next,
+	}})
+	return err
+}
+
+func (s *FeedStore) RecordRun(ctx context.Context, run models.ScrapeRun) error {
+	_, err := s.runs.InsertOne(ctx, run)
+	return err
+}
+
+func (s *FeedStore) ListRuns(ctx context.Context, feedID primitive.ObjectID, limit, offset int64) ([]models.ScrapeRun, int64, error) {
+	filter := bson.M{"feed_id": feedID}
+	total, err := s.runs.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "started_at", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cursor, err := s.runs.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []models.ScrapeRun{}
+	err = cursor.All(ctx, &runs)
+	return runs, total, err
+}
+
+func (s *FeedStore) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Feed, error) {
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var feeds []models.Feed
+	err = cursor.All(ctx, &feeds)
+	return feeds, err
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FollowerStore is the MongoDB store.FollowerStore, backed by "feed_followers"
+type FollowerStore struct {
+	collection *mongo.Collection
+}
+
+// Follow relies on the unique (feed_id, user_id) index, so two concurrent
+// follows of the same feed store only one subscription
+func (s *FollowerStore) Follow(ctx context.Context, follower models.FeedFollower) error {
+	_, err := s.collection.InsertOne(ctx, follower)
+	if mongo.IsDuplicateKeyError(err) {
+		return store.ErrDuplicate
+	}
+	return err
+}
+
+func (s *FollowerStore) Unfollow(ctx context.Context, feedID primitive.ObjectID, userID string) error {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"feed_id": feedID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *FollowerStore) ListByUser(ctx context.Context, userID string) ([]models.FeedFollower, error) {
+	return s.find(ctx, bson.M{"user_id": userID})
+}
+
+func (s *FollowerStore) ListByFeed(ctx context.Context, feedID primitive.ObjectID) ([]models.FeedFollower, error) {
+	return s.find(ctx, bson.M{"feed_id": feedID})
+}
+
+func (s *FollowerStore) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (s *FollowerStore) DeleteByFeed(ctx context.Context, feedID primitive.ObjectID) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"feed_id": feedID})
+	return err
+}
+
+func (s *FollowerStore) find(ctx context.Context, filter bson.M) ([]models.FeedFollower, error) {
+	cursor, err := s.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var followers []models.FeedFollower
+	err = cursor.All(ctx, &followers)
+	return followers, err
+}
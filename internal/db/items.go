@@ -0,0 +1,300 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/rss"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ItemStore is the MongoDB store.ItemStore, backed by "feed_items" and
+// "item_revisions"
+type ItemStore struct {
+	collection *mongo.Collection
+	revisions  *mongo.Collection
+}
+
+// InsertNew upserts every item by identity key. The unique index on
+// (feed_id, identity_key) settles races with an overlapping scrape of the
+// same feed, so duplicate-key errors just mean the item was already seen.
+func (s *ItemStore) InsertNew(ctx context.Context, items []models.FeedItem) ([]models.FeedItem, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	writes := make([]mongo.WriteModel, 0, len(items))
+	for _, item := range items {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(itemIdentityFilter(item)).
+			SetUpdate(bson.M{"$setOnInsert": item}).
+			SetUpsert(true))
+	}
+
+	result, err := s.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil && !onlyDuplicateKeyErrors(err) {
+		return nil, err
+	}
+	var inserted []models.FeedItem
+	for index, item := range items {
+		if _, ok := result.UpsertedIDs[int64(index)]; ok {
+			inserted = append(inserted, item)
+		}
+	}
+	return inserted, nil
+}
+
+func (s *ItemStore) FindByKeys(ctx context.Context, feedID primitive.ObjectID, keys []string) ([]models.FeedItem, error) {
+	return s.find(ctx, bson.M{"feed_id": feedID, "identity_key": bson.M{"$in": keys}}, options.Find())
+}
+
+func (s *ItemStore) ApplyEdit(ctx context.Context, previous, updated models.FeedItem, revision models.ItemRevision) (bool, error) {
+	// Only update the content we compared against, so two overlapping
+	// scrapes cannot both record the same edit
+	filter := bson.M{"_id": previous.ID, "content_hash": previous.ContentHash}
+	if previous.ContentHash == "" {
+		filter["content_hash"] = bson.M{"$exists": false}
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"title":        updated.Title,
+			"link":         updated.Link,
+			"description":  updated.Description,
+			"author":       updated.Author,
+			"categories":   updated.Categories,
+			"enclosures":   updated.Enclosures,
+			"content_hash": updated.ContentHash,
+			"updated_at":   updated.UpdatedAt,
+		},
+		"$inc": bson.M{"revision": 1},
+	}
+	result, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount == 0 {
+		return false, err
+	}
+	if _, err := s.revisions.InsertOne(ctx, revision); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *ItemStore) Get(ctx context.Context, id primitive.ObjectID) (models.FeedItem, error) {
+	var item models.FeedItem
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&item)
+	return item, notFound(err)
+}
+
+func (s *ItemStore) ListByFeed(ctx context.Context, feedID primitive.ObjectID) ([]models.FeedItem, error) {
+	return s.find(ctx, bson.M{"feed_id": feedID}, options.Find())
+}
+
+func (s *ItemStore) Revisions(ctx context.Context, itemID primitive.ObjectID) ([]models.ItemRevision, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "revision", Value: -1}})
+	cursor, err := s.revisions.Find(ctx, bson.M{"item_id": itemID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []models.ItemRevision{}
+	err = cursor.All(ctx, &revisions)
+	return revisions, err
+}
+
+func (s *ItemStore) SetStarred(ctx context.Context, id primitive.ObjectID, starred bool) error {
+	update := bson.M{"$set": bson.M{"starred": true}}
+	if !starred {
+		update = bson.M{"$unset": bson.M{"starred": ""}}
+	}
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *ItemStore) Search(ctx context.Context, query string, feedID primitive.ObjectID, limit int64) ([]models.FeedItem, error) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	// Quoting each word makes $text require all of them
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, "") + `"`
+	}
+	filter := bson.M{"$text": bson.M{"$search": strings.Join(words, " ")}}
+	if !feedID.IsZero() {
+		filter["feed_id"] = feedID
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}).
+		SetLimit(limit)
+	return s.find(ctx, filter, opts)
+}
+
+func (s *ItemStore) PrunableByAge(ctx context.Context, feedID primitive.ObjectID, cutoff time.Time) ([]primitive.ObjectID, error) {
+	return s.ids(ctx, bson.M{
+		"feed_id":  feedID,
+		"starred":  bson.M{"$ne": true},
+		"pub_date": bson.M{"$lt": cutoff},
+	}, options.Find())
+}
+
+func (s *ItemStore) PrunableByCount(ctx context.Context, feedID primitive.ObjectID, keep int) ([]primitive.ObjectID, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "pub_date", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(keep))
+	return s.ids(ctx, bson.M{"feed_id": feedID, "starred": bson.M{"$ne": true}}, opts)
+}
+
+func (s *ItemStore) FeedIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	values, err := s.collection.Distinct(ctx, "feed_id", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var ids []primitive.ObjectID
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *ItemStore) CountByFeeds(ctx context.Context, feedIDs []primitive.ObjectID) (int64, error) {
+	if len(feedIDs) == 0 {
+		return 0, nil
+	}
+	return s.collection.CountDocuments(ctx, bson.M{"feed_id": bson.M{"$in": feedIDs}, "starred": bson.M{"$ne": true}})
+}
+
+func (s *ItemStore) Delete(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result, err := s.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "starred": bson.M{"$ne": true}})
+	if err != nil {
+		return 0, err
+	}
+	if err := s.deleteOrphanedRevisions(ctx, ids); err != nil {
+		return result.DeletedCount, err
+	}
+	return result.DeletedCount, nil
+}
+
+// deleteOrphanedRevisions drops the revisions of those of the given items
+// that no longer exist. Starred items survive deletion and keep theirs.
+func (s *ItemStore) deleteOrphanedRevisions(ctx context.Context, ids []primitive.ObjectID) error {
+	survivors, err := s.ids(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find())
+	if err != nil {
+		return err
+	}
+	kept := make(map[primitive.ObjectID]bool, len(survivors))
+	for _, id := range survivors {
+		kept[id] = true
+	}
+	var deleted []primitive.ObjectID
+	for _, id := range ids {
+		if !kept[id] {
+			deleted = append(deleted, id)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	_, err = s.revisions.DeleteMany(ctx, bson.M{"item_id": bson.M{"$in": deleted}})
+	return err
+}
+
+func (s *ItemStore) DeleteByFeed(ctx context.Context, feedID primitive.ObjectID) (int64, error) {
+	result, err := s.collection.DeleteMany(ctx, bson.M{"feed_id": feedID, "starred": bson.M{"$ne": true}})
+	if err != nil {
+		return 0, err
+	}
+	// Keep the revisions of the items left behind
+	remaining, err := s.ids(ctx, bson.M{"feed_id": feedID}, options.Find())
+	if err != nil {
+		return result.DeletedCount, err
+	}
+	filter := bson.M{"feed_id": feedID}
+	if len(remaining) > 0 {
+		filter["item_id"] = bson.M{"$nin": remaining}
+	}
+	if _, err := s.revisions.DeleteMany(ctx, filter); err != nil {
+		return result.DeletedCount, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (s *ItemStore) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.FeedItem, error) {
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []models.FeedItem
+	err = cursor.All(ctx, &items)
+	return items, err
+}
+
+// ids returns the IDs of the items matching filter
+func (s *ItemStore) ids(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]primitive.ObjectID, error) {
+	cursor, err := s.collection.Find(ctx, filter, opts.SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var found []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(found))
+	for _, item := range found {
+		ids = append(ids, item.ID)
+	}
+	return ids, nil
+}
+
+// itemIdentityFilter matches the stored copy of an item. Items keyed by GUID
+// or content hash also match an item stored under its link before the feed
+// started sending GUIDs.
+func itemIdentityFilter(item models.FeedItem) bson.M {
+	linkKey := "link:" + rss.NormalizeLink(item.Link)
+	if item.Link == "" || item.IdentityKey == linkKey {
+		return bson.M{"feed_id": item.FeedID, "identity_key": item.IdentityKey}
+	}
+	return bson.M{
+		"feed_id": item.FeedID,
+		"$or": []bson.M{
+			{"identity_key": item.IdentityKey},
+			{"identity_key": linkKey, "guid": bson.M{"$exists": false}},
+		},
+	}
+}
+
+// onlyDuplicateKeyErrors reports whether a bulk write failed solely because
+// some of its items were already stored
+func onlyDuplicateKeyErrors(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
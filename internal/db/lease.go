@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/lease"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Lease is a named lock stored in the "leases" collection. At most one
+// holder owns it at a time; the owner must renew it before its TTL runs out
+// or any other instance may take it over. Expiry is judged by the database
+// server's clock so instances need not agree on the time.
+type Lease struct {
+	collection *mongo.Collection
+	name       string
+	holder     string
+	ttl        time.Duration
+}
+
+// NewLease returns a handle on the named lease for this process
+func NewLease(database *mongo.Database, name string, ttl time.Duration) *Lease {
+	return &Lease{
+		collection: database.Collection("leases"),
+		name:       name,
+		holder:     lease.HolderID(),
+		ttl:        ttl,
+	}
+}
+
+func (l *Lease) Name() string {
+	return l.name
+}
+
+// Holder identifies this process as a lease holder
+func (l *Lease) Holder() string {
+	return l.holder
+}
+
+func (l *Lease) TTL() time.Duration {
+	return l.ttl
+}
+
+// TryAcquire takes the lease if it is free or expired, or extends it if this
+// process already holds it. It reports whether the lease is now held.
+func (l *Lease) TryAcquire(ctx context.Context) (bool, error) {
+	filter := bson.M{
+		"_id": l.name,
+		"$or": bson.A{
+			bson.M{"holder": l.holder},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$expires_at", "$$NOW"}}},
+		},
+	}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"holder":      l.holder,
+		"renewed_at":  "$$NOW",
+		"expires_at":  bson.M{"$add": bson.A{"$$NOW", l.ttl.Milliseconds()}},
+		"acquired_at": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$holder", l.holder}}, "$acquired_at", "$$NOW"}},
+	}}}}
+
+	_, err := l.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Someone else holds an unexpired lease, so the upsert collided
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release gives up the lease if this process holds it, so another instance
+// can take over without waiting for it to expire
+func (l *Lease) Release(ctx context.Context) error {
+	_, err := l.collection.DeleteOne(ctx, bson.M{"_id": l.name, "holder": l.holder})
+	return err
+}
+
+// RunWhileLeader campaigns for the lease until ctx is cancelled, running fn
+// whenever this process holds it. See lease.RunWhileLeader.
+func (l *Lease) RunWhileLeader(ctx context.Context, fn func(context.Context)) {
+	lease.RunWhileLeader(ctx, l, fn)
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is one versioned change to the schema: an index, a backfill or
+// both. Migrations must be safe to re-run, since two instances booting at
+// once may both apply one before either has recorded it.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, database *mongo.Database) error
+}
+
+// MigrationRecord is the entry stored in "schema_migrations" once a
+// migration has been applied
+type MigrationRecord struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+	DurationMs  int64     `bson:"duration_ms"`
+}
+
+// MigrationStatus pairs a known migration with its record, if applied
+type MigrationStatus struct {
+	Migration
+	Applied *MigrationRecord
+}
+
+// Migrations lists every migration in version order. New migrations are
+// appended with the next version; released ones are never edited.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "index scrape_runs by feed and expire old runs",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("scrape_runs").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys: bson.D{{Key: "feed_id", Value: 1}, {Key: "started_at", Value: -1}},
+				},
+				{
+					Keys:    bson.D{{Key: "started_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(int32(store.ScrapeRunRetention.Seconds())),
+				},
+			})
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "backfill feed_items identity keys and index them uniquely per feed",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			// The unique index can only be built once every stored item has a key
+			items := database.Collection("feed_items")
+			if err := backfillIdentityKeys(ctx, items); err != nil {
+				return fmt.Errorf("backfill identity keys: %w", err)
+			}
+			_, err := items.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "feed_id", Value: 1}, {Key: "identity_key", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		},
+	},
+	{
+		Version:     3,
+		Description: "index feed_items by feed and publication date",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("feed_items").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "feed_id", Value: 1}, {Key: "pub_date", Value: -1}},
+			})
+			return err
+		},
+	},
+	{
+		Version:     4,
+		Description: "index feed_followers by user and by feed",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("feed_followers").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys: bson.D{{Key: "user_id", Value: 1}},
+				},
+				{
+					Keys: bson.D{{Key: "feed_id", Value: 1}, {Key: "user_id", Value: 1}},
+				},
+			})
+			return err
+		},
+	},
+	{
+		Version:     5,
+		Description: "index users by firebase_uid",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "firebase_uid", Value: 1}},
+			})
+			return err
+		},
+	},
+	{
+		Version:     6,
+		Description: "index feeds by next fetch time for the scheduler",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("feeds").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "next_fetch_at", Value: 1}},
+			})
+			return err
+		},
+	},
+	{
+		Version:     7,
+		Description: "index item_revisions by item",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("item_revisions").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "item_id", Value: 1}, {Key: "revision", Value: -1}},
+			})
+			return err
+		},
+	},
+	{
+		Version:     8,
+		Description: "text index feed_items titles and descriptions for search",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("feed_items").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+				Options: options.Index().SetWeights(bson.M{"title": 3, "description": 1}),
+			})
+			return err
+		},
+	},
+	{
+		Version:     9,
+		Description: "remove duplicate feed_followers and index them uniquely by feed and user",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			followers := database.Collection("feed_followers")
+			if err := dedupeFollowers(ctx, followers); err != nil {
+				return fmt.Errorf("dedupe followers: %w", err)
+			}
+			// The index from migration 4 has the same keys, so it has to go
+			// before the unique one can be built
+			_, err := followers.Indexes().DropOne(ctx, "feed_id_1_user_id_1")
+			if err != nil && !isIndexNotFound(err) {
+				return err
+			}
+			_, err = followers.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "feed_id", Value: 1}, {Key: "user_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		},
+	},
+	{
+		Version:     10,
+		Description: "rekey feed_items whose link has a fragment",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			return rekeyFragmentLinks(ctx, database.Collection("feed_items"))
+		},
+	},
+}
+
+// isIndexNotFound reports whether dropping an index failed because it does
+// not exist, as when a migration is re-run
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 27 || cmdErr.Name == "IndexNotFound")
+}
+
+// Migrate applies every migration that has not been recorded yet, in
+// version order, and returns the ones it applied. It stops at the first
+// failure so later migrations never run against a half-migrated schema.
+func Migrate(ctx context.Context, database *mongo.Database) ([]Migration, error) {
+	records := database.Collection("schema_migrations")
+
+	applied, err := appliedMigrations(ctx, records)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		start := time.Now()
+		if err := migration.Up(ctx, database); err != nil {
+			return ran, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		record := MigrationRecord{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now(),
+			DurationMs:  time.Since(start).Milliseconds(),
+		}
+		_, err := records.ReplaceOne(ctx, bson.M{"_id": migration.Version}, record, options.Replace().SetUpsert(true))
+		if err != nil {
+			return ran, fmt.Errorf("record migration %d: %w", migration.Version, err)
+		}
+		log.Printf("Applied migration %d (%s) in %v", migration.Version, migration.Description, time.Since(start))
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// Status reports every known migration and whether it has been applied
+func Status(ctx context.Context, database *mongo.Database) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(ctx, database.Collection("schema_migrations"))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(Migrations))
+	for _, migration := range Migrations {
+		status := MigrationStatus{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = &record
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// appliedMigrations loads the recorded migrations keyed by version
+func appliedMigrations(ctx context.Context, records *mongo.Collection) (map[int]MigrationRecord, error) {
+	cursor, err := records.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var found []MigrationRecord
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]MigrationRecord, len(found))
+	for _, record := range found {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
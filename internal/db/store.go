@@ -0,0 +1,35 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NewStores returns the MongoDB implementations of the stores, kept in the
+// given database
+func NewStores(database *mongo.Database) store.Stores {
+	return store.Stores{
+		Users:     &UserStore{collection: database.Collection("users")},
+		Feeds:     &FeedStore{collection: database.Collection("feeds"), runs: database.Collection("scrape_runs")},
+		Items:     &ItemStore{collection: database.Collection("feed_items"), revisions: database.Collection("item_revisions")},
+		Followers: &FollowerStore{collection: database.Collection("feed_followers")},
+	}
+}
+
+// notFound translates the driver's missing-document error into the store's
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+// The MongoDB stores implement the store interfaces
+var (
+	_ store.UserStore     = (*UserStore)(nil)
+	_ store.FeedStore     = (*FeedStore)(nil)
+	_ store.ItemStore     = (*ItemStore)(nil)
+	_ store.FollowerStore = (*FollowerStore)(nil)
+)
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UserStore is the MongoDB store.UserStore, backed by "users"
+type UserStore struct {
+	collection *mongo.Collection
+}
+
+func (s *UserStore) Create(ctx context.Context, user models.User) error {
+	_, err := s.collection.InsertOne(ctx, user)
+	return err
+}
+
+func (s *UserStore) Get(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	var user models.User
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	return user, notFound(err)
+}
+
+func (s *UserStore) GetByFirebaseUID(ctx context.Context, uid string) (models.User, error) {
+	var user models.User
+	err := s.collection.FindOne(ctx, bson.M{"firebase_uid": uid}).Decode(&user)
+	return user, notFound(err)
+}
+
+func (s *UserStore) Update(ctx context.Context, id primitive.ObjectID, user models.User) error {
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": user})
+	return err
+}
+
+func (s *UserStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
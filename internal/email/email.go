@@ -2,8 +2,6 @@ package email
 
 import (
 	"fmt"
-	"log"
-	"os"
 	"strings"
 	"time"
 
@@ -11,20 +9,35 @@ import (
 	"gopkg.in/gomail.v2"
 )
 
-var mailClient *gomail.Dialer
-
-func InitEmailClient() {
-	host := "smtp.gmail.com"
-	port := 587
-	username := os.Getenv("EMAIL_USER")
-	password := os.Getenv("EMAIL_PASS")
+// Config holds the SMTP server notifications are sent through
+type Config struct {
+	Host     string
+	Port     int
+	Username string // also the sender address
+	Password string
+}
 
-	if username == "" || password == "" {
-		log.Fatal("EMAIL_USER and EMAIL_PASS must be set in environment variables")
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Host: "smtp.gmail.com",
+		Port: 587,
 	}
+}
 
-	mailClient = gomail.NewDialer(host, port, username, password)
+var mailClient *gomail.Dialer
+var sender string
+
+func InitEmailClient(cfg Config) {
+	mailClient = gomail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password)
 	mailClient.TLSConfig = nil // Use STARTTLS
+	sender = cfg.Username
+}
+
+// Enabled reports whether InitEmailClient has been called, so there is an
+// SMTP server to send notifications through
+func Enabled() bool {
+	return mailClient != nil
 }
 
 func SendFeedUpdateEmail(to, username, feedName string, newItems []models.FeedItem) error {
@@ -33,7 +46,7 @@ func SendFeedUpdateEmail(to, username, feedName string, newItems []models.FeedIt
 	}
 
 	m := gomail.NewMessage()
-	m.SetHeader("From", fmt.Sprintf("Scrapper Team <%s>", os.Getenv("EMAIL_USER")))
+	m.SetHeader("From", fmt.Sprintf("Scrapper Team <%s>", sender))
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", fmt.Sprintf("New Items in Your Followed Feed: %s", feedName))
 	m.SetHeader("X-Priority", "3")
@@ -3,19 +3,25 @@ package handlers
 import (
     "context"
     "encoding/json"
+    "errors"
+    "fmt"
     "log"
     "net/http"
+    "strconv"
     "strings"
+    "sync"
     "time"
 
+    "firebase.google.com/go/v4/auth"
     "github.com/gorilla/mux"
     "github.com/kwabena369/scrapper/internal/db"
     "github.com/kwabena369/scrapper/internal/email"
+    "github.com/kwabena369/scrapper/internal/jobs"
     "github.com/kwabena369/scrapper/internal/models"
+    "github.com/kwabena369/scrapper/internal/retention"
     "github.com/kwabena369/scrapper/internal/rss"
-    "go.mongodb.org/mongo-driver/bson"
+    "github.com/kwabena369/scrapper/internal/store"
     "go.mongodb.org/mongo-driver/bson/primitive"
-    "go.mongodb.org/mongo-driver/mongo"
 )
 
 // Feed is re-exported for use in main.go
@@ -47,8 +53,36 @@ func TheLoggingMiddleware(next http.Handler) http.Handler {
     })
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
+// AuthConfig says how AuthMiddleware identifies the caller
+type AuthConfig struct {
+    // Client verifies Firebase ID tokens
+    Client *auth.Client
+    // Disabled lets every request through as the local user instead of
+    // verifying a token, for single-user setups without Firebase
+    Disabled bool
+}
+
+// localUser is who every request acts as when authentication is disabled
+var localUser = db.UserClaims{UID: "local"}
+
+func AuthMiddleware(cfg AuthConfig) mux.MiddlewareFunc {
+    return func(next http.Handler) http.Handler {
+        return authenticate(cfg, next)
+    }
+}
+
+func authenticate(cfg AuthConfig, next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if cfg.Disabled {
+            claims := localUser
+            ctx := context.WithValue(r.Context(), "user", &claims)
+            next.ServeHTTP(w, r.WithContext(ctx))
+            return
+        }
+        if cfg.Client == nil {
+            RespondWithError(w, http.StatusInternalServerError, "Authentication is not configured")
+            return
+        }
         authHeader := r.Header.Get("Authorization")
         if authHeader == "" {
             RespondWithError(w, http.StatusUnauthorized, "No token provided")
@@ -59,13 +93,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
             RespondWithError(w, http.StatusUnauthorized, "Invalid token format")
             return
         }
-        user, err := db.AuthClient.VerifyIDToken(context.Background(), tokenStr)
+        user, err := cfg.Client.VerifyIDToken(context.Background(), tokenStr)
         if err != nil {
             RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
             return
         }
         // Convert *auth.Token to *db.UserClaims
-        fullUser, err := db.AuthClient.GetUser(context.Background(), user.UID)
+        fullUser, err := cfg.Client.GetUser(context.Background(), user.UID)
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to get user details")
             return
@@ -79,7 +113,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
     })
 }
 
-func CreateUser(client *mongo.Client) http.HandlerFunc {
+func CreateUser(users store.UserStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         var user models.User
         if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
@@ -87,11 +121,10 @@ func CreateUser(client *mongo.Client) http.HandlerFunc {
             return
         }
         user.ID = primitive.NewObjectID()
-        collection := client.Database("hope").Collection("users")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        _, err := collection.InsertOne(ctx, user)
+        err := users.Create(ctx, user)
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to create user")
             return
@@ -100,7 +133,7 @@ func CreateUser(client *mongo.Client) http.HandlerFunc {
     }
 }
 
-func GetUser(client *mongo.Client) http.HandlerFunc {
+func GetUser(users store.UserStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         id := mux.Vars(r)["id"]
         objectID, err := primitive.ObjectIDFromHex(id)
@@ -108,12 +141,10 @@ func GetUser(client *mongo.Client) http.HandlerFunc {
             RespondWithError(w, http.StatusBadRequest, "Invalid ID")
             return
         }
-        collection := client.Database("hope").Collection("users")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        var user models.User
-        err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+        user, err := users.Get(ctx, objectID)
         if err != nil {
             RespondWithError(w, http.StatusNotFound, "User not found")
             return
@@ -122,7 +153,7 @@ func GetUser(client *mongo.Client) http.HandlerFunc {
     }
 }
 
-func UpdateUser(client *mongo.Client) http.HandlerFunc {
+func UpdateUser(users store.UserStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         id := mux.Vars(r)["id"]
         objectID, err := primitive.ObjectIDFromHex(id)
@@ -135,11 +166,10 @@ func UpdateUser(client *mongo.Client) http.HandlerFunc {
             RespondWithError(w, http.StatusBadRequest, "Invalid input")
             return
         }
-        collection := client.Database("hope").Collection("users")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        _, err = collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": user})
+        err = users.Update(ctx, objectID, user)
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to update user")
             return
@@ -148,7 +178,7 @@ func UpdateUser(client *mongo.Client) http.HandlerFunc {
     }
 }
 
-func DeleteUser(client *mongo.Client) http.HandlerFunc {
+func DeleteUser(users store.UserStore, followers store.FollowerStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         id := mux.Vars(r)["id"]
         objectID, err := primitive.ObjectIDFromHex(id)
@@ -156,17 +186,17 @@ func DeleteUser(client *mongo.Client) http.HandlerFunc {
             RespondWithError(w, http.StatusBadRequest, "Invalid ID")
             return
         }
-        collection := client.Database("hope").Collection("users")
-        feedFollowerCollection := client.Database("hope").Collection("feed_followers")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        _, err = feedFollowerCollection.DeleteMany(ctx, bson.M{"user_id": objectID})
-        if err != nil {
-            log.Printf("Failed to delete feed followers: %v", err)
+        // Follows are recorded against the Firebase UID
+        if user, err := users.Get(ctx, objectID); err == nil {
+            if err := followers.DeleteByUser(ctx, user.FirebaseUID); err != nil {
+                log.Printf("Failed to delete feed followers: %v", err)
+            }
         }
 
-        _, err = collection.DeleteOne(ctx, bson.M{"_id": objectID})
+        err = users.Delete(ctx, objectID)
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to delete user")
             return
@@ -175,16 +205,39 @@ func DeleteUser(client *mongo.Client) http.HandlerFunc {
     }
 }
 
-func CreateFeed(client *mongo.Client) http.HandlerFunc {
+// feedRequest is the body of a feed create or update. It holds only the
+// fields a client may set; scheduling, validators and fetch health are
+// maintained by the server.
+type feedRequest struct {
+    Name         string
+    Url          string
+    UserID       primitive.ObjectID
+    PollInterval int
+    Retention    *models.RetentionPolicy
+}
+
+// feed returns a feed with the fields of the request set
+func (req feedRequest) feed() models.Feed {
+    return models.Feed{
+        Name:         req.Name,
+        Url:          req.Url,
+        UserID:       req.UserID,
+        PollInterval: req.PollInterval,
+        Retention:    req.Retention,
+    }
+}
+
+func CreateFeed(feeds store.FeedStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         log.Println("Received request to create feed")
 
-        var feed models.Feed
-        if err := json.NewDecoder(r.Body).Decode(&feed); err != nil {
+        var req feedRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
             log.Printf("Error decoding request body: %v", err)
             RespondWithError(w, http.StatusBadRequest, "Invalid input")
             return
         }
+        feed := req.feed()
         log.Printf("Decoded feed: %+v", feed)
 
         if feed.Name == "" || feed.Url == "" || feed.UserID.IsZero() {
@@ -192,17 +245,27 @@ func CreateFeed(client *mongo.Client) http.HandlerFunc {
             RespondWithError(w, http.StatusBadRequest, "Missing required fields")
             return
         }
+        if err := validatePollInterval(feed.PollInterval); err != nil {
+            RespondWithError(w, http.StatusBadRequest, err.Error())
+            return
+        }
+        if err := validateRetention(feed.Retention); err != nil {
+            RespondWithError(w, http.StatusBadRequest, err.Error())
+            return
+        }
 
         feed.ID = primitive.NewObjectID()
         feed.CreatedAt = time.Now()
         feed.UpdatedAt = time.Now()
+        // New feeds are due for their first fetch straight away
+        nextFetchAt := feed.CreatedAt
+        feed.NextFetchAt = &nextFetchAt
         log.Printf("Saving feed with ID: %s", feed.ID.Hex())
 
-        collection := client.Database("hope").Collection("feeds")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        _, err := collection.InsertOne(ctx, feed)
+        err := feeds.Create(ctx, feed)
         if err != nil {
             log.Printf("Error saving feed to MongoDB: %v", err)
             RespondWithError(w, http.StatusInternalServerError, "Failed to create feed")
@@ -213,7 +276,7 @@ func CreateFeed(client *mongo.Client) http.HandlerFunc {
     }
 }
 
-func GetFeed(client *mongo.Client) http.HandlerFunc {
+func GetFeed(feeds store.FeedStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         id := mux.Vars(r)["id"]
         objectID, err := primitive.ObjectIDFromHex(id)
@@ -221,12 +284,10 @@ func GetFeed(client *mongo.Client) http.HandlerFunc {
             RespondWithError(w, http.StatusBadRequest, "Invalid ID")
             return
         }
-        collection := client.Database("hope").Collection("feeds")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        var feed models.Feed
-        err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&feed)
+        feed, err := feeds.Get(ctx, objectID)
         if err != nil {
             RespondWithError(w, http.StatusNotFound, "Feed not found")
             return
@@ -235,7 +296,7 @@ func GetFeed(client *mongo.Client) http.HandlerFunc {
     }
 }
 
-func UpdateFeed(client *mongo.Client) http.HandlerFunc {
+func UpdateFeed(feeds store.FeedStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         id := mux.Vars(r)["id"]
         objectID, err := primitive.ObjectIDFromHex(id)
@@ -243,27 +304,67 @@ func UpdateFeed(client *mongo.Client) http.HandlerFunc {
             RespondWithError(w, http.StatusBadRequest, "Invalid ID")
             return
         }
-        var feed models.Feed
-        if err := json.NewDecoder(r.Body).Decode(&feed); err != nil {
+        var req feedRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
             RespondWithError(w, http.StatusBadRequest, "Invalid input")
             return
         }
-        feed.ID = objectID
-        feed.UpdatedAt = time.Now()
-        collection := client.Database("hope").Collection("feeds")
+        feed := req.feed()
+        if err := validatePollInterval(feed.PollInterval); err != nil {
+            RespondWithError(w, http.StatusBadRequest, err.Error())
+            return
+        }
+        if err := validateRetention(feed.Retention); err != nil {
+            RespondWithError(w, http.StatusBadRequest, err.Error())
+            return
+        }
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        _, err = collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": feed})
+        existing, err := feeds.Get(ctx, objectID)
+        if errors.Is(err, store.ErrNotFound) {
+            RespondWithError(w, http.StatusNotFound, "Feed not found")
+            return
+        }
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to update feed")
             return
         }
-        RespondWithJSON(w, http.StatusOK, feed)
+        settings := store.FeedSettings{
+            Name:         feed.Name,
+            Url:          feed.Url,
+            UserID:       feed.UserID,
+            PollInterval: feed.PollInterval,
+            Retention:    feed.Retention,
+            UpdatedAt:    time.Now(),
+        }
+        // Apply a changed interval from now rather than after the old one,
+        // including a fixed one given up for the adaptive schedule
+        if feed.PollInterval != existing.PollInterval {
+            settings.NextFetchAt = &settings.UpdatedAt
+        }
+        // A new URL is a new source: its validators and health start over
+        // and it is fetched straight away
+        if feed.Url != existing.Url {
+            settings.ResetFetchState = true
+            settings.NextFetchAt = &settings.UpdatedAt
+        }
+
+        err = feeds.Update(ctx, objectID, settings)
+        if err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to update feed")
+            return
+        }
+        updated, err := feeds.Get(ctx, objectID)
+        if err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to update feed")
+            return
+        }
+        RespondWithJSON(w, http.StatusOK, updated)
     }
 }
 
-func DeleteFeed(client *mongo.Client) http.HandlerFunc {
+func DeleteFeed(feeds store.FeedStore, items store.ItemStore, followers store.FollowerStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         id := mux.Vars(r)["id"]
         objectID, err := primitive.ObjectIDFromHex(id)
@@ -271,17 +372,22 @@ func DeleteFeed(client *mongo.Client) http.HandlerFunc {
             RespondWithError(w, http.StatusBadRequest, "Invalid ID")
             return
         }
-        collection := client.Database("hope").Collection("feeds")
-        feedFollowerCollection := client.Database("hope").Collection("feed_followers")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        _, err = feedFollowerCollection.DeleteMany(ctx, bson.M{"feed_id": objectID})
+        // Starred items outlive the feed. Items a running scrape stores after
+        // this are picked up by the retention janitor.
+        if _, err := items.DeleteByFeed(ctx, objectID); err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to delete feed items")
+            return
+        }
+
+        err = followers.DeleteByFeed(ctx, objectID)
         if err != nil {
             log.Printf("Failed to delete feed followers: %v", err)
         }
 
-        _, err = collection.DeleteOne(ctx, bson.M{"_id": objectID})
+        err = feeds.Delete(ctx, objectID)
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to delete feed")
             return
@@ -290,29 +396,84 @@ func DeleteFeed(client *mongo.Client) http.HandlerFunc {
     }
 }
 
-func GetAllFeeds(client *mongo.Client) http.HandlerFunc {
+func GetAllFeeds(feeds store.FeedStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        collection := client.Database("hope").Collection("feeds")
+        filter := store.FeedFilter{Health: r.URL.Query().Get("health")}
+        switch filter.Health {
+        case store.HealthAny, store.HealthHealthy, store.HealthUnhealthy, store.HealthPaused:
+        default:
+            RespondWithError(w, http.StatusBadRequest, "health must be one of healthy, unhealthy or paused")
+            return
+        }
+
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        cursor, err := collection.Find(ctx, bson.M{})
+        list, err := feeds.List(ctx, filter)
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to fetch feeds")
             return
         }
-        defer cursor.Close(ctx)
+        RespondWithJSON(w, http.StatusOK, list)
+    }
+}
+
+// FeedHealth summarizes how reliably a feed has been fetched
+type FeedHealth struct {
+    FeedID              string     `json:"feed_id"`
+    Status              string     `json:"status"`
+    LastAttemptAt       *time.Time `json:"last_attempt_at,omitempty"`
+    LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+    LastHTTPStatus      int        `json:"last_http_status,omitempty"`
+    ConsecutiveFailures int        `json:"consecutive_failures"`
+    LastError           string     `json:"last_error,omitempty"`
+    LastErrorCode       string     `json:"last_error_code,omitempty"`
+    LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+    PausedAt            *time.Time `json:"paused_at,omitempty"`
+}
+
+func GetFeedHealth(feeds store.FeedStore) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        id := mux.Vars(r)["id"]
+        objectID, err := primitive.ObjectIDFromHex(id)
+        if err != nil {
+            RespondWithError(w, http.StatusBadRequest, "Invalid ID")
+            return
+        }
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
 
-        var feeds []models.Feed
-        if err = cursor.All(ctx, &feeds); err != nil {
-            RespondWithError(w, http.StatusInternalServerError, "Failed to decode feeds")
+        feed, err := feeds.Get(ctx, objectID)
+        if err != nil {
+            RespondWithError(w, http.StatusNotFound, "Feed not found")
             return
         }
-        RespondWithJSON(w, http.StatusOK, feeds)
+
+        status := "healthy"
+        if feed.Paused {
+            status = "paused"
+        } else if feed.ConsecutiveFailures > 0 {
+            status = "failing"
+        } else if feed.LastAttemptAt == nil {
+            status = "unknown"
+        }
+
+        RespondWithJSON(w, http.StatusOK, FeedHealth{
+            FeedID:              feed.ID.Hex(),
+            Status:              status,
+            LastAttemptAt:       feed.LastAttemptAt,
+            LastSuccessAt:       feed.LastSuccessAt,
+            LastHTTPStatus:      feed.LastHTTPStatus,
+            ConsecutiveFailures: feed.ConsecutiveFailures,
+            LastError:           feed.LastError,
+            LastErrorCode:       feed.LastErrorCode,
+            LastErrorAt:         feed.LastErrorAt,
+            PausedAt:            feed.PausedAt,
+        })
     }
 }
 
-func FollowFeed(client *mongo.Client) http.HandlerFunc {
+func FollowFeed(followers store.FollowerStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         user := r.Context().Value("user").(*db.UserClaims)
         if user == nil {
@@ -340,21 +501,9 @@ func FollowFeed(client *mongo.Client) http.HandlerFunc {
             return
         }
 
-        collection := client.Database("hope").Collection("feed_followers")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        // Check if already following
-        count, err := collection.CountDocuments(ctx, bson.M{"feed_id": feedID, "user_id": input.UserID})
-        if err != nil {
-            RespondWithError(w, http.StatusInternalServerError, "Failed to check follow status")
-            return
-        }
-        if count > 0 {
-            RespondWithError(w, http.StatusConflict, "Already following this feed")
-            return
-        }
-
         follower := models.FeedFollower{
             ID:        primitive.NewObjectID(),
             FeedID:    feedID,
@@ -362,7 +511,15 @@ func FollowFeed(client *mongo.Client) http.HandlerFunc {
             CreatedAt: time.Now(),
         }
 
-        _, err = collection.InsertOne(ctx, follower)
+        err = followers.Follow(ctx, follower)
+        if errors.Is(err, store.ErrDuplicate) {
+            RespondWithError(w, http.StatusConflict, "Already following this feed")
+            return
+        }
+        if errors.Is(err, store.ErrNotFound) {
+            RespondWithError(w, http.StatusNotFound, "Feed not found")
+            return
+        }
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to follow feed")
             return
@@ -371,7 +528,7 @@ func FollowFeed(client *mongo.Client) http.HandlerFunc {
     }
 }
 
-func UnfollowFeed(client *mongo.Client) http.HandlerFunc {
+func UnfollowFeed(followers store.FollowerStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         user := r.Context().Value("user").(*db.UserClaims)
         if user == nil {
@@ -386,24 +543,23 @@ func UnfollowFeed(client *mongo.Client) http.HandlerFunc {
             return
         }
 
-        collection := client.Database("hope").Collection("feed_followers")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        result, err := collection.DeleteOne(ctx, bson.M{"feed_id": feedID, "user_id": user.UID})
-        if err != nil {
-            RespondWithError(w, http.StatusInternalServerError, "Failed to unfollow feed")
+        err = followers.Unfollow(ctx, feedID, user.UID)
+        if errors.Is(err, store.ErrNotFound) {
+            RespondWithError(w, http.StatusNotFound, "Follow relationship not found")
             return
         }
-        if result.DeletedCount == 0 {
-            RespondWithError(w, http.StatusNotFound, "Follow relationship not found")
+        if err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to unfollow feed")
             return
         }
         RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Unfollowed feed"})
     }
 }
 
-func GetFollowedFeeds(client *mongo.Client) http.HandlerFunc {
+func GetFollowedFeeds(followers store.FollowerStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         user := r.Context().Value("user").(*db.UserClaims)
         if user == nil {
@@ -411,27 +567,29 @@ func GetFollowedFeeds(client *mongo.Client) http.HandlerFunc {
             return
         }
 
-        collection := client.Database("hope").Collection("feed_followers")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        cursor, err := collection.Find(ctx, bson.M{"user_id": user.UID})
+        followed, err := followers.ListByUser(ctx, user.UID)
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to fetch followed feeds")
             return
         }
-        defer cursor.Close(ctx)
-
-        var followers []models.FeedFollower
-        if err = cursor.All(ctx, &followers); err != nil {
-            RespondWithError(w, http.StatusInternalServerError, "Failed to decode followed feeds")
-            return
-        }
-        RespondWithJSON(w, http.StatusOK, followers)
+        RespondWithJSON(w, http.StatusOK, followed)
     }
 }
 
-func ScrapeFeedLogic(client *mongo.Client, feedID string) (int, []models.FeedItem, error) {
+// ScrapeFeedLogic fetches a feed and stores its new items. Cancelling ctx
+// abandons the fetch and any pending reads or writes.
+func ScrapeFeedLogic(ctx context.Context, stores store.Stores, fetcher *rss.Fetcher, feedID string) (int, []models.FeedItem, error) {
+    run := models.ScrapeRun{ID: primitive.NewObjectID(), StartedAt: time.Now()}
+    newItemsCount, newFeedItems, err := scrapeFeed(ctx, stores, fetcher, feedID, &run)
+    recordScrapeRun(stores.Feeds, &run, err)
+    return newItemsCount, newFeedItems, err
+}
+
+// scrapeFeed does the work of ScrapeFeedLogic, filling in run as it goes
+func scrapeFeed(ctx context.Context, stores store.Stores, fetcher *rss.Fetcher, feedID string, run *models.ScrapeRun) (int, []models.FeedItem, error) {
     startTime := time.Now()
     log.Printf("Starting ScrapeFeedLogic for feed %s", feedID)
 
@@ -441,124 +599,440 @@ func ScrapeFeedLogic(client *mongo.Client, feedID string) (int, []models.FeedIte
     }
 
     // Fetch feed
-    collection := client.Database("hope").Collection("feeds")
-    ctxFeed, cancelFeed := context.WithTimeout(context.Background(), 10*time.Second)
+    ctxFeed, cancelFeed := context.WithTimeout(ctx, 10*time.Second)
     defer cancelFeed()
 
-    var feed models.Feed
-    err = collection.FindOne(ctxFeed, bson.M{"_id": objectID}).Decode(&feed)
+    feed, err := stores.Feeds.Get(ctxFeed, objectID)
     if err != nil {
         log.Printf("Failed to fetch feed %s: %v", feedID, err)
         return 0, nil, err
     }
     log.Printf("Fetched feed %s in %v", feedID, time.Since(startTime))
+    run.FeedID = feed.ID
 
-    // Fetch existing items
-    itemCollection := client.Database("hope").Collection("feed_items")
-    ctxFetch, cancelFetch := context.WithTimeout(context.Background(), 10*time.Second)
-    defer cancelFetch()
-
+    // Fetch RSS items, letting the publisher answer 304 if nothing changed
     fetchStart := time.Now()
-    cursor, err := itemCollection.Find(ctxFetch, bson.M{"feed_id": objectID})
-    if err != nil {
-        log.Printf("Failed to fetch existing items for feed %s: %v", feedID, err)
-        return 0, nil, err
-    }
-    defer cursor.Close(ctxFetch)
-
-    existingLinks := make(map[string]bool)
-    var existingItems []models.FeedItem
-    if err = cursor.All(ctxFetch, &existingItems); err != nil {
-        log.Printf("Failed to decode existing items for feed %s: %v", feedID, err)
-        return 0, nil, err
-    }
-    for _, item := range existingItems {
-        existingLinks[item.Link] = true
-    }
-    log.Printf("Fetched %d existing items for feed %s in %v", len(existingItems), feedID, time.Since(fetchStart))
-
-    // Fetch RSS items
-    items, err := rss.FetchRSS(feed.Url)
+    result, err := fetcher.Fetch(ctx, feed.Url, feed.ETag, feed.LastModified)
     if err != nil {
         log.Printf("Failed to fetch RSS for feed %s: %v", feedID, err)
+        var statusErr *rss.StatusError
+        if errors.As(err, &statusErr) {
+            run.HTTPStatus = statusErr.StatusCode
+        }
+        recordFetchError(stores.Feeds, objectID, err)
         return 0, nil, err
     }
+    run.HTTPStatus = result.StatusCode
+    run.NotModified = result.NotModified
+    run.Bytes = result.Bytes
+    run.ItemsParsed = len(result.Items)
+    if result.NotModified {
+        log.Printf("Feed %s not modified since last fetch", feedID)
+        recordFetchSuccess(stores.Feeds, feed, result)
+        return 0, nil, nil
+    }
+    items := result.Items
     log.Printf("Fetched %d RSS items for feed %s in %v", len(items), feedID, time.Since(fetchStart))
 
-    // Prepare new items for batch insert
-    var newItems []interface{}
-    var newFeedItems []models.FeedItem
+    var candidates []models.FeedItem
+    badDates := make(map[primitive.ObjectID]bool)
+    seenKeys := make(map[string]bool)
+    firstSeen := time.Now()
     for _, item := range items {
-        if existingLinks[item.Link] {
-            continue
-        }
-
-        pubDate, err := rss.ParsePubDate(item.PubDate)
-        if err != nil {
-            log.Printf("Failed to parse pubDate for item %s: %v", item.Title, err)
+        // Skip repeats within the same document
+        identityKey := item.IdentityKey()
+        if seenKeys[identityKey] {
             continue
         }
+        seenKeys[identityKey] = true
 
         feedItem := models.FeedItem{
             ID:          primitive.NewObjectID(),
             FeedID:      feed.ID,
+            GUID:        item.GUID,
+            IdentityKey: identityKey,
             Title:       item.Title,
             Link:        item.Link,
             Description: item.Description,
-            PubDate:     pubDate,
+            Author:      item.Author,
+            Categories:  item.Categories,
+            Enclosures:  toEnclosures(item.Enclosures),
+            ContentHash: item.ContentHash(),
         }
-        newItems = append(newItems, feedItem)
-        newFeedItems = append(newFeedItems, feedItem)
+        // Fall back to the time we first saw the item rather than dropping it
+        if feedItem.PubDate, err = rss.ParsePubDate(item.PubDate); err != nil {
+            feedItem.PubDate = firstSeen
+            badDates[feedItem.ID] = true
+        }
+        candidates = append(candidates, feedItem)
     }
 
-    // Batch insert new items
-    newItemsCount := len(newItems)
-    if newItemsCount > 0 {
-        insertStart := time.Now()
-        ctxInsert, cancelInsert := context.WithTimeout(context.Background(), 10*time.Second)
-        defer cancelInsert()
+    var newFeedItems []models.FeedItem
+    if len(candidates) > 0 {
+        upsertStart := time.Now()
+        ctxUpsert, cancelUpsert := context.WithTimeout(ctx, 10*time.Second)
+        defer cancelUpsert()
 
-        _, err = itemCollection.InsertMany(ctxInsert, newItems)
+        newFeedItems, err = stores.Items.InsertNew(ctxUpsert, candidates)
+        if err != nil {
+            log.Printf("Failed to upsert %d items for feed %s: %v", len(candidates), feedID, err)
+            return 0, nil, err
+        }
+        inserted := make(map[primitive.ObjectID]bool, len(newFeedItems))
+        for _, item := range newFeedItems {
+            inserted[item.ID] = true
+            if badDates[item.ID] {
+                log.Printf("Failed to parse pubDate for item %s, using first-seen time", item.Title)
+                run.ItemsBadDate++
+            }
+        }
+        log.Printf("Upserted %d items for feed %s (%d new) in %v", len(candidates), feedID, len(newFeedItems), time.Since(upsertStart))
+
+        // Items already stored may have been edited since we last saw them
+        var knownItems []models.FeedItem
+        for _, item := range candidates {
+            if !inserted[item.ID] {
+                knownItems = append(knownItems, item)
+            }
+        }
+        run.ItemsUpdated, err = recordItemEdits(ctxUpsert, stores.Items, feed.ID, knownItems)
         if err != nil {
-            log.Printf("Failed to batch insert %d items for feed %s: %v", newItemsCount, feedID, err)
+            log.Printf("Failed to record edited items for feed %s: %v", feedID, err)
             return 0, nil, err
         }
-        log.Printf("Batch inserted %d new items for feed %s in %v", newItemsCount, feedID, time.Since(insertStart))
+        if run.ItemsUpdated > 0 {
+            log.Printf("Updated %d edited items for feed %s", run.ItemsUpdated, feedID)
+        }
+    }
 
-        // Notify followers
-        go notifyFollowers(client, feed, newFeedItems)
+    newItemsCount := len(newFeedItems)
+    if newItemsCount > 0 {
+        run.ItemsNew = newItemsCount
+
+        // Notify followers, when there is an SMTP server to send through
+        if email.Enabled() {
+            notifications.Add(1)
+            go func() {
+                defer notifications.Done()
+                notifyFollowers(stores, feed, newFeedItems)
+            }()
+        }
     } else {
         log.Printf("No new items to insert for feed %s", feedID)
     }
 
+    // Remember the validators only once the items are stored, so a failed
+    // insert is retried in full on the next run
+    recordFetchSuccess(stores.Feeds, feed, result)
+
     log.Printf("Completed ScrapeFeedLogic for feed %s in %v", feedID, time.Since(startTime))
     return newItemsCount, newFeedItems, nil
 }
 
-func notifyFollowers(client *mongo.Client, feed models.Feed, newItems []models.FeedItem) {
+// recordItemEdits compares items already stored with their latest fetched
+// copy by content hash. Edited items are updated in place and their previous
+// content is kept as a revision. It returns the number of items updated.
+func recordItemEdits(ctx context.Context, items store.ItemStore, feedID primitive.ObjectID, fetched []models.FeedItem) (int, error) {
+    if len(fetched) == 0 {
+        return 0, nil
+    }
+
+    // Load only the stored copies of the fetched items, including any kept
+    // under their link before the feed sent GUIDs
+    keys := make([]string, 0, len(fetched))
+    for _, item := range fetched {
+        keys = append(keys, item.IdentityKey)
+        if item.Link != "" {
+            keys = append(keys, "link:"+rss.NormalizeLink(item.Link))
+        }
+    }
+    storedItems, err := items.FindByKeys(ctx, feedID, keys)
+    if err != nil {
+        return 0, err
+    }
+    stored := make(map[string]models.FeedItem, len(storedItems))
+    for _, item := range storedItems {
+        stored[item.IdentityKey] = item
+    }
+
+    updated := 0
+    for _, item := range fetched {
+        previous, ok := stored[item.IdentityKey]
+        if !ok && item.Link != "" {
+            previous, ok = stored["link:"+rss.NormalizeLink(item.Link)]
+            ok = ok && previous.GUID == ""
+        }
+        if !ok {
+            continue
+        }
+        previousHash := storedContentHash(previous)
+        if previousHash == item.ContentHash {
+            continue
+        }
+
+        now := time.Now()
+        item.UpdatedAt = &now
+        revision := models.ItemRevision{
+            ID:          primitive.NewObjectID(),
+            ItemID:      previous.ID,
+            FeedID:      feedID,
+            Revision:    previous.Revision,
+            Title:       previous.Title,
+            Link:        previous.Link,
+            Description: previous.Description,
+            Author:      previous.Author,
+            Categories:  previous.Categories,
+            Enclosures:  previous.Enclosures,
+            ContentHash: previousHash,
+            ReplacedAt:  now,
+        }
+        applied, err := items.ApplyEdit(ctx, previous, item, revision)
+        if err != nil {
+            return updated, err
+        }
+        if applied {
+            updated++
+        }
+    }
+    return updated, nil
+}
+
+// storedContentHash returns the content hash of a stored item. It is derived
+// from the stored content rather than read back, so items saved before hashes
+// were recorded, or hashed by an earlier version of rss.ContentHash, are
+// not mistaken for edited ones.
+func storedContentHash(item models.FeedItem) string {
+    var enclosureURLs []string
+    for _, enclosure := range item.Enclosures {
+        enclosureURLs = append(enclosureURLs, enclosure.URL)
+    }
+    return rss.ContentHash(item.Title, item.Link, item.Description, item.Author, item.Categories, enclosureURLs)
+}
+
+// recordScrapeRun stores the history record of a scrape. Runs that never
+// got as far as loading the feed are not recorded.
+func recordScrapeRun(feeds store.FeedStore, run *models.ScrapeRun, scrapeErr error) {
+    if run.FeedID.IsZero() {
+        return
+    }
+    run.FinishedAt = time.Now()
+    run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+    if scrapeErr != nil {
+        run.Error = scrapeErr.Error()
+        run.ErrorCode = ScrapeErrorCode(scrapeErr)
+    }
+
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    // Fetch followers
-    followerCollection := client.Database("hope").Collection("feed_followers")
-    cursor, err := followerCollection.Find(ctx, bson.M{"feed_id": feed.ID})
+    if err := feeds.RecordRun(ctx, *run); err != nil {
+        log.Printf("Failed to record scrape run for feed %s: %v", run.FeedID.Hex(), err)
+    }
+}
+
+// Bounds and default for a feed's poll interval
+var (
+    DefaultPollInterval = time.Hour
+    MinPollInterval     = 5 * time.Minute
+    MaxPollInterval     = 24 * time.Hour
+)
+
+// PollIntervalFor returns a feed's configured poll interval clamped to the
+// bounds, or the default for feeds that leave polling to the scheduler
+func PollIntervalFor(feed models.Feed) time.Duration {
+    if feed.PollInterval == 0 {
+        return DefaultPollInterval
+    }
+    interval := time.Duration(feed.PollInterval) * time.Second
+    if interval < MinPollInterval {
+        return MinPollInterval
+    }
+    if interval > MaxPollInterval {
+        return MaxPollInterval
+    }
+    return interval
+}
+
+// validatePollInterval checks a requested poll interval, in seconds, against
+// the configured bounds. Zero selects the default.
+func validatePollInterval(seconds int) error {
+    if seconds == 0 {
+        return nil
+    }
+    interval := time.Duration(seconds) * time.Second
+    if interval < MinPollInterval || interval > MaxPollInterval {
+        return fmt.Errorf("poll interval must be between %d and %d seconds", int(MinPollInterval.Seconds()), int(MaxPollInterval.Seconds()))
+    }
+    return nil
+}
+
+// validateRetention checks a feed's retention override. Zero limits fall
+// back to the global default.
+func validateRetention(policy *models.RetentionPolicy) error {
+    if policy == nil {
+        return nil
+    }
+    if policy.MaxAge < 0 || policy.MaxItems < 0 {
+        return errors.New("retention limits must not be negative")
+    }
+    return nil
+}
+
+// MaxConsecutiveFailures is how many fetches in a row may fail before a feed
+// is paused and skipped by the scheduler
+var MaxConsecutiveFailures = 10
+
+// recordFetchSuccess stores the cache validators of a successful fetch,
+// resets the feed's failure count and clears any error or pause left by
+// previous failures
+func recordFetchSuccess(feeds store.FeedStore, feed models.Feed, result *rss.FetchResult) {
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    success := store.FetchSuccess{
+        At:           time.Now(),
+        HTTPStatus:   result.StatusCode,
+        ETag:         result.ETag,
+        LastModified: result.LastModified,
+    }
+    // A 304 carries no body, so keep the hints from the last full fetch
+    if !result.NotModified {
+        hints := toPollingHints(result.Hints)
+        success.Hints = &hints
+    }
+    if err := feeds.RecordFetchSuccess(ctx, feed.ID, success); err != nil {
+        log.Printf("Failed to record fetch result for feed %s: %v", feed.ID.Hex(), err)
+    }
+}
+
+// toPollingHints converts parsed polling hints into their stored form. The
+// stricter of <ttl> and the syndication module's period becomes the minimum
+// interval.
+func toPollingHints(hints rss.PollingHints) models.PollingHints {
+    minInterval := hints.TTL
+    if hints.UpdateInterval > minInterval {
+        minInterval = hints.UpdateInterval
+    }
+    stored := models.PollingHints{
+        MinInterval: int(minInterval.Seconds()),
+        SkipHours:   hints.SkipHours,
+    }
+    for _, day := range hints.SkipDays {
+        stored.SkipDays = append(stored.SkipDays, day.String())
+    }
+    return stored
+}
+
+// recordFetchError persists why the last fetch of a feed failed and pauses
+// the feed once it has failed MaxConsecutiveFailures times in a row
+func recordFetchError(feeds store.FeedStore, feedID primitive.ObjectID, fetchErr error) {
+    // Being deferred by a rate-limited host says nothing about this feed
+    var deferredErr *rss.DeferredError
+    if errors.As(fetchErr, &deferredErr) {
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    now := time.Now()
+    _, code := scrapeErrorStatus(fetchErr)
+    failure := store.FetchFailure{
+        At:        now,
+        Error:     fetchErr.Error(),
+        ErrorCode: code,
+    }
+    var statusErr *rss.StatusError
+    if errors.As(fetchErr, &statusErr) {
+        failure.HTTPStatus = statusErr.StatusCode
+    }
+
+    feed, err := feeds.RecordFetchFailure(ctx, feedID, failure)
     if err != nil {
-        log.Printf("Failed to fetch followers for feed %s: %v", feed.ID.Hex(), err)
+        log.Printf("Failed to record fetch error for feed %s: %v", feedID.Hex(), err)
         return
     }
-    defer cursor.Close(ctx)
 
-    var followers []models.FeedFollower
-    if err = cursor.All(ctx, &followers); err != nil {
-        log.Printf("Failed to decode followers for feed %s: %v", feed.ID.Hex(), err)
+    if MaxConsecutiveFailures > 0 && feed.ConsecutiveFailures >= MaxConsecutiveFailures && !feed.Paused {
+        if err := feeds.Pause(ctx, feedID, now); err != nil {
+            log.Printf("Failed to pause feed %s: %v", feedID.Hex(), err)
+            return
+        }
+        log.Printf("Paused feed %s after %d consecutive failures", feedID.Hex(), feed.ConsecutiveFailures)
+    }
+}
+
+// scrapeErrorStatus maps an error from ScrapeFeedLogic to the HTTP status
+// and error code returned by the API
+func scrapeErrorStatus(err error) (int, string) {
+    var statusErr *rss.StatusError
+    var networkErr *rss.NetworkError
+    var parseErr *rss.ParseError
+    var tooLargeErr *rss.TooLargeError
+    var deferredErr *rss.DeferredError
+    switch {
+    case errors.As(err, &deferredErr):
+        return http.StatusTooManyRequests, "feed_deferred"
+    case errors.As(err, &statusErr):
+        return http.StatusBadGateway, "feed_http_error"
+    case errors.As(err, &networkErr):
+        if networkErr.Timeout() {
+            return http.StatusGatewayTimeout, "feed_timeout"
+        }
+        return http.StatusBadGateway, "feed_network_error"
+    case errors.As(err, &parseErr):
+        return http.StatusUnprocessableEntity, "feed_parse_error"
+    case errors.As(err, &tooLargeErr):
+        return http.StatusBadGateway, "feed_too_large"
+    case errors.Is(err, primitive.ErrInvalidHex):
+        return http.StatusBadRequest, "invalid_feed_id"
+    case errors.Is(err, store.ErrNotFound):
+        return http.StatusNotFound, "feed_not_found"
+    default:
+        return http.StatusInternalServerError, "scrape_failed"
+    }
+}
+
+// toEnclosures converts parsed enclosures into their stored form
+func toEnclosures(enclosures []rss.Enclosure) []models.Enclosure {
+    var result []models.Enclosure
+    for _, enclosure := range enclosures {
+        if enclosure.URL == "" {
+            continue
+        }
+        length, _ := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64)
+        result = append(result, models.Enclosure{
+            URL:    enclosure.URL,
+            Type:   enclosure.Type,
+            Length: length,
+        })
+    }
+    return result
+}
+
+// notifications tracks follower emails still being sent
+var notifications sync.WaitGroup
+
+// WaitForNotifications blocks until the follower emails of every finished
+// scrape have been sent. Call it once no more scrapes can start.
+func WaitForNotifications() {
+    notifications.Wait()
+}
+
+func notifyFollowers(stores store.Stores, feed models.Feed, newItems []models.FeedItem) {
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    // Fetch followers
+    followers, err := stores.Followers.ListByFeed(ctx, feed.ID)
+    if err != nil {
+        log.Printf("Failed to fetch followers for feed %s: %v", feed.ID.Hex(), err)
         return
     }
 
     // Fetch user emails
-    userCollection := client.Database("hope").Collection("users")
     for _, follower := range followers {
-        var user models.User
-        err = userCollection.FindOne(ctx, bson.M{"firebase_uid": follower.UserID}).Decode(&user)
+        user, err := stores.Users.GetByFirebaseUID(ctx, follower.UserID)
         if err != nil {
             log.Printf("Failed to fetch user %s for notification: %v", follower.UserID, err)
             continue
@@ -574,23 +1048,128 @@ func notifyFollowers(client *mongo.Client, feed models.Feed, newItems []models.F
     }
 }
 
-func ScrapeFeed(client *mongo.Client) http.HandlerFunc {
+// ScrapeFeed queues a scrape of the feed and answers 202 Accepted with the
+// job, which can be polled at /v1/scrape-jobs/{id}
+func ScrapeFeed(feeds store.FeedStore, queue *jobs.Queue) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         id := mux.Vars(r)["id"]
-        newItemsCount, _, err := ScrapeFeedLogic(client, id)
+        objectID, err := primitive.ObjectIDFromHex(id)
         if err != nil {
-            RespondWithError(w, http.StatusInternalServerError, "Failed to scrape feed: "+err.Error())
+            RespondWithError(w, http.StatusBadRequest, "Invalid Feed ID")
+            return
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+
+        _, err = feeds.Get(ctx, objectID)
+        if errors.Is(err, store.ErrNotFound) {
+            RespondWithError(w, http.StatusNotFound, "Feed not found")
+            return
+        }
+        if err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to fetch feed")
+            return
+        }
+
+        job, coalesced, err := queue.Enqueue(ctx, objectID)
+        if err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to queue scrape")
+            return
+        }
+
+        w.Header().Set("Location", "/v1/scrape-jobs/"+job.ID.Hex())
+        RespondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+            "message":   "Feed scrape queued",
+            "job_id":    job.ID.Hex(),
+            "status":    job.Status,
+            "coalesced": coalesced,
+        })
+    }
+}
+
+func GetScrapeJob(queue *jobs.Queue) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        id := mux.Vars(r)["id"]
+        objectID, err := primitive.ObjectIDFromHex(id)
+        if err != nil {
+            RespondWithError(w, http.StatusBadRequest, "Invalid ID")
+            return
+        }
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+
+        job, err := queue.Get(ctx, objectID)
+        if err != nil {
+            RespondWithError(w, http.StatusNotFound, "Scrape job not found")
+            return
+        }
+        RespondWithJSON(w, http.StatusOK, job)
+    }
+}
+
+// ScrapeErrorCode classifies an error from ScrapeFeedLogic for API clients
+func ScrapeErrorCode(err error) string {
+    _, code := scrapeErrorStatus(err)
+    return code
+}
+
+// GetFeedRuns lists a feed's scrape history, newest first. Use limit (at
+// most 100) and offset to page through it.
+func GetFeedRuns(feeds store.FeedStore) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        id := mux.Vars(r)["id"]
+        objectID, err := primitive.ObjectIDFromHex(id)
+        if err != nil {
+            RespondWithError(w, http.StatusBadRequest, "Invalid Feed ID")
+            return
+        }
+
+        limit, offset, err := pagination(r, 20, 100)
+        if err != nil {
+            RespondWithError(w, http.StatusBadRequest, err.Error())
+            return
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+
+        runs, total, err := feeds.ListRuns(ctx, objectID, limit, offset)
+        if err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to fetch scrape runs")
             return
         }
 
         RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-            "message":    "Feed scraped successfully",
-            "new_items":  newItemsCount,
+            "runs":   runs,
+            "total":  total,
+            "limit":  limit,
+            "offset": offset,
         })
     }
 }
 
-func GetFeedItems(client *mongo.Client) http.HandlerFunc {
+// pagination reads the limit and offset query parameters
+func pagination(r *http.Request, defaultLimit, maxLimit int64) (int64, int64, error) {
+    limit, offset := defaultLimit, int64(0)
+    if v := r.URL.Query().Get("limit"); v != "" {
+        n, err := strconv.ParseInt(v, 10, 64)
+        if err != nil || n < 1 || n > maxLimit {
+            return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+        }
+        limit = n
+    }
+    if v := r.URL.Query().Get("offset"); v != "" {
+        n, err := strconv.ParseInt(v, 10, 64)
+        if err != nil || n < 0 {
+            return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+        }
+        offset = n
+    }
+    return limit, offset, nil
+}
+
+func GetFeedItems(items store.ItemStore) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         id := mux.Vars(r)["id"]
         objectID, err := primitive.ObjectIDFromHex(id)
@@ -599,23 +1178,168 @@ func GetFeedItems(client *mongo.Client) http.HandlerFunc {
             return
         }
 
-        collection := client.Database("hope").Collection("feed_items")
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        cursor, err := collection.Find(ctx, bson.M{"feed_id": objectID})
+        list, err := items.ListByFeed(ctx, objectID)
         if err != nil {
             RespondWithError(w, http.StatusInternalServerError, "Failed to fetch feed items")
             return
         }
-        defer cursor.Close(ctx)
 
-        var items []models.FeedItem
-        if err = cursor.All(ctx, &items); err != nil {
-            RespondWithError(w, http.StatusInternalServerError, "Failed to decode feed items")
+        RespondWithJSON(w, http.StatusOK, list)
+    }
+}
+
+// SearchItems finds items whose title or description contains every word
+// of q, best match first. feed_id limits the search to one feed and limit
+// (at most 100) caps the results.
+func SearchItems(items store.ItemStore) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        query := strings.TrimSpace(r.URL.Query().Get("q"))
+        if query == "" {
+            RespondWithError(w, http.StatusBadRequest, "q is required")
+            return
+        }
+        var feedID primitive.ObjectID
+        if v := r.URL.Query().Get("feed_id"); v != "" {
+            id, err := primitive.ObjectIDFromHex(v)
+            if err != nil {
+                RespondWithError(w, http.StatusBadRequest, "Invalid Feed ID")
+                return
+            }
+            feedID = id
+        }
+        limit, _, err := pagination(r, 20, 100)
+        if err != nil {
+            RespondWithError(w, http.StatusBadRequest, err.Error())
+            return
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+
+        found, err := items.Search(ctx, query, feedID, limit)
+        if err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to search items")
+            return
+        }
+        if found == nil {
+            found = []models.FeedItem{}
+        }
+        RespondWithJSON(w, http.StatusOK, found)
+    }
+}
+
+// GetItemRevisions returns a feed item together with the earlier versions of
+// its content, newest first
+func GetItemRevisions(items store.ItemStore) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        id := mux.Vars(r)["id"]
+        objectID, err := primitive.ObjectIDFromHex(id)
+        if err != nil {
+            RespondWithError(w, http.StatusBadRequest, "Invalid Item ID")
+            return
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+
+        item, err := items.Get(ctx, objectID)
+        if err != nil {
+            if errors.Is(err, store.ErrNotFound) {
+                RespondWithError(w, http.StatusNotFound, "Item not found")
+                return
+            }
+            RespondWithError(w, http.StatusInternalServerError, "Failed to fetch item")
+            return
+        }
+
+        revisions, err := items.Revisions(ctx, objectID)
+        if err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to fetch item revisions")
+            return
+        }
+
+        RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+            "item":      item,
+            "revisions": revisions,
+        })
+    }
+}
+
+// SetItemStarred returns a handler that stars or unstars an item. Starred
+// items are never pruned by retention.
+func SetItemStarred(items store.ItemStore, starred bool) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        id := mux.Vars(r)["id"]
+        objectID, err := primitive.ObjectIDFromHex(id)
+        if err != nil {
+            RespondWithError(w, http.StatusBadRequest, "Invalid Item ID")
+            return
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+
+        err = items.SetStarred(ctx, objectID, starred)
+        if errors.Is(err, store.ErrNotFound) {
+            RespondWithError(w, http.StatusNotFound, "Item not found")
+            return
+        }
+        if err != nil {
+            RespondWithError(w, http.StatusInternalServerError, "Failed to update item")
+            return
+        }
+        RespondWithJSON(w, http.StatusOK, map[string]interface{}{"item_id": id, "starred": starred})
+    }
+}
+
+// PreviewRetention is a dry run of the retention janitor: it reports what
+// the next pass would prune, for every feed or for ?feed_id= only
+func PreviewRetention(feeds store.FeedStore, items store.ItemStore, cfg retention.Config) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        var feedID primitive.ObjectID
+        if id := r.URL.Query().Get("feed_id"); id != "" {
+            var err error
+            if feedID, err = primitive.ObjectIDFromHex(id); err != nil {
+                RespondWithError(w, http.StatusBadRequest, "Invalid Feed ID")
+                return
+            }
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        defer cancel()
+
+        plan, err := retention.Preview(ctx, feeds, items, cfg, feedID)
+        if err != nil {
+            if errors.Is(err, store.ErrNotFound) {
+                RespondWithError(w, http.StatusNotFound, "Feed not found")
+                return
+            }
+            RespondWithError(w, http.StatusInternalServerError, "Failed to preview retention")
             return
         }
 
-        RespondWithJSON(w, http.StatusOK, items)
+        feeds := []map[string]interface{}{}
+        total := plan.OrphanedItems
+        for _, feedPlan := range plan.Feeds {
+            total += int64(len(feedPlan.ItemIDs))
+            feeds = append(feeds, map[string]interface{}{
+                "feed_id":        feedPlan.Feed.ID.Hex(),
+                "name":           feedPlan.Feed.Name,
+                "max_age":        int(feedPlan.Policy.MaxAge.Seconds()),
+                "max_items":      feedPlan.Policy.MaxItems,
+                "prune_by_age":   feedPlan.ByAge,
+                "prune_by_count": feedPlan.ByCount,
+                "prune_total":    len(feedPlan.ItemIDs),
+            })
+        }
+        RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+            "feeds":          feeds,
+            "orphaned_feeds": len(plan.OrphanedFeeds),
+            "orphaned_items": plan.OrphanedItems,
+            "prune_total":    total,
+        })
     }
-}
\ No newline at end of file
+}
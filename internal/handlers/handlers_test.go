@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kwabena369/scrapper/internal/db"
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/rss"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// feedServer serves an RSS document of two items, the first with the
+// current title and link
+type feedServer struct {
+	*httptest.Server
+	title atomic.Value
+	link  atomic.Value
+}
+
+func newFeedServer(t *testing.T) *feedServer {
+	t.Helper()
+	s := &feedServer{}
+	s.title.Store("First")
+	s.link.Store("http://example.com/first")
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Test</title>
+<item><title>%s</title><link>%s</link><pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate></item>
+<item><title>Second</title><link>http://example.com/second</link><guid>second</guid><pubDate>Tue, 03 Jan 2006 15:04:05 GMT</pubDate></item>
+</channel></rss>`, s.title.Load(), s.link.Load())
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestFetcher(t *testing.T) *rss.Fetcher {
+	t.Helper()
+	cfg := rss.DefaultFetcherConfig()
+	cfg.HostDelay = 0
+	fetcher, err := rss.NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fetcher
+}
+
+func createFeed(t *testing.T, stores store.Stores, url string) models.Feed {
+	t.Helper()
+	feed := models.Feed{ID: primitive.NewObjectID(), Name: "Test", Url: url, UserID: primitive.NewObjectID(), CreatedAt: time.Now()}
+	if err := stores.Feeds.Create(context.Background(), feed); err != nil {
+		t.Fatal(err)
+	}
+	return feed
+}
+
+func TestScrapeFeedLogic(t *testing.T) {
+	stores := store.NewMemoryStores()
+	server := newFeedServer(t)
+	fetcher := newTestFetcher(t)
+	feed := createFeed(t, stores, server.URL)
+	ctx := context.Background()
+	defer WaitForNotifications()
+
+	scrape := func() int {
+		t.Helper()
+		n, _, err := ScrapeFeedLogic(ctx, stores, fetcher, feed.ID.Hex())
+		if err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		return n
+	}
+	revisions := func() int {
+		t.Helper()
+		items, err := stores.Items.ListByFeed(ctx, feed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := 0
+		for _, item := range items {
+			list, err := stores.Items.Revisions(ctx, item.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			total += len(list)
+		}
+		return total
+	}
+
+	if n := scrape(); n != 2 {
+		t.Fatalf("first scrape stored %d new items, want 2", n)
+	}
+	if n := scrape(); n != 0 {
+		t.Fatalf("repeated scrape stored %d new items, want 0", n)
+	}
+
+	// Rotating tracking parameters is neither a new item nor an edit
+	server.link.Store("http://example.com/first?utm_source=rss&amp;fbclid=abc")
+	if n := scrape(); n != 0 {
+		t.Fatalf("scrape with tracking parameters stored %d new items, want 0", n)
+	}
+	if n := revisions(); n != 0 {
+		t.Fatalf("tracking parameters recorded %d revisions, want 0", n)
+	}
+
+	// An edited title updates the item and keeps the old version
+	server.title.Store("First, edited")
+	if n := scrape(); n != 0 {
+		t.Fatalf("scrape of an edit stored %d new items, want 0", n)
+	}
+	if n := revisions(); n != 1 {
+		t.Fatalf("edit recorded %d revisions, want 1", n)
+	}
+
+	runs, total, err := stores.Feeds.ListRuns(ctx, feed.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 || runs[0].ItemsUpdated != 1 {
+		t.Fatalf("got %d runs with the latest updating %d items, want 4 and 1", total, runs[0].ItemsUpdated)
+	}
+}
+
+func TestScrapeFeedLogicSendsValidators(t *testing.T) {
+	stores := store.NewMemoryStores()
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == "Mon, 02 Jan 2006 15:04:05 GMT" {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		fmt.Fprint(w, `<rss version="2.0"><channel><item><title>One</title><guid>one</guid></item></channel></rss>`)
+	}))
+	defer server.Close()
+	fetcher := newTestFetcher(t)
+	feed := createFeed(t, stores, server.URL)
+	ctx := context.Background()
+	defer WaitForNotifications()
+
+	for i, want := range []int{1, 0} {
+		n, _, err := ScrapeFeedLogic(ctx, stores, fetcher, feed.ID.Hex())
+		if err != nil || n != want {
+			t.Fatalf("scrape %d stored %d new items, %v; want %d", i, n, err, want)
+		}
+	}
+	if requests.Load() != 2 || notModified.Load() != 1 {
+		t.Fatalf("%d requests with %d answered 304, want 2 and 1", requests.Load(), notModified.Load())
+	}
+
+	stored, err := stores.Feeds.Get(ctx, feed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ETag != `"v1"` || stored.LastHTTPStatus != http.StatusNotModified {
+		t.Fatalf("validators not kept across a 304: etag %q, status %d", stored.ETag, stored.LastHTTPStatus)
+	}
+	runs, _, err := stores.Feeds.ListRuns(ctx, feed.ID, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || !runs[0].NotModified {
+		t.Fatalf("latest run not marked not modified: %+v", runs)
+	}
+}
+
+func TestScrapeFeedLogicRecordsFailures(t *testing.T) {
+	stores := store.NewMemoryStores()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	feed := createFeed(t, stores, server.URL)
+
+	if _, _, err := ScrapeFeedLogic(context.Background(), stores, newTestFetcher(t), feed.ID.Hex()); err == nil {
+		t.Fatal("scrape of a failing feed succeeded")
+	}
+	stored, err := stores.Feeds.Get(context.Background(), feed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ConsecutiveFailures != 1 || stored.LastHTTPStatus != http.StatusInternalServerError || stored.LastError == "" {
+		t.Fatalf("failure not recorded: %+v", stored)
+	}
+}
+
+func TestScrapeErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{&rss.DeferredError{URL: "http://example.com", Host: "example.com", Until: time.Now()}, http.StatusTooManyRequests, "feed_deferred"},
+		{&rss.StatusError{URL: "http://example.com", StatusCode: 404}, http.StatusBadGateway, "feed_http_error"},
+		{&rss.NetworkError{URL: "http://example.com", Err: context.DeadlineExceeded}, http.StatusGatewayTimeout, "feed_timeout"},
+		{&rss.NetworkError{URL: "http://example.com", Err: fmt.Errorf("connection refused")}, http.StatusBadGateway, "feed_network_error"},
+		{&rss.ParseError{URL: "http://example.com", Err: fmt.Errorf("bad XML")}, http.StatusUnprocessableEntity, "feed_parse_error"},
+		{&rss.TooLargeError{URL: "http://example.com", Limit: 1}, http.StatusBadGateway, "feed_too_large"},
+		{fmt.Errorf("loading feed: %w", store.ErrNotFound), http.StatusNotFound, "feed_not_found"},
+		{primitive.ErrInvalidHex, http.StatusBadRequest, "invalid_feed_id"},
+		{fmt.Errorf("disk full"), http.StatusInternalServerError, "scrape_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			status, code := scrapeErrorStatus(tt.err)
+			if status != tt.status || code != tt.code {
+				t.Errorf("scrapeErrorStatus(%v) = %d %s, want %d %s", tt.err, status, code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestCreateFeedIgnoresServerManagedFields(t *testing.T) {
+	stores := store.NewMemoryStores()
+	userID := primitive.NewObjectID()
+	body := fmt.Sprintf(`{
+		"Name": "News", "Url": "http://example.com/rss", "UserID": %q, "PollInterval": 3600,
+		"ETag": "forged", "ConsecutiveFailures": 7, "Paused": true,
+		"NextFetchAt": "2999-01-01T00:00:00Z", "CurrentInterval": 99
+	}`, userID.Hex())
+
+	rec := httptest.NewRecorder()
+	CreateFeed(stores.Feeds)(rec, httptest.NewRequest("POST", "/v1/feeds", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	feeds, err := stores.Feeds.List(context.Background(), store.FeedFilter{})
+	if err != nil || len(feeds) != 1 {
+		t.Fatalf("got %d feeds, %v", len(feeds), err)
+	}
+	feed := feeds[0]
+	if feed.Name != "News" || feed.UserID != userID || feed.PollInterval != 3600 {
+		t.Fatalf("editable fields not stored: %+v", feed)
+	}
+	if feed.ETag != "" || feed.ConsecutiveFailures != 0 || feed.Paused || feed.CurrentInterval != 0 {
+		t.Fatalf("server-managed fields taken from the request: %+v", feed)
+	}
+	if feed.NextFetchAt == nil || feed.NextFetchAt.After(time.Now()) {
+		t.Fatalf("new feed not due straight away: %v", feed.NextFetchAt)
+	}
+}
+
+func TestUpdateFeedKeepsServerManagedFields(t *testing.T) {
+	stores := store.NewMemoryStores()
+	feed := createFeed(t, stores, "http://example.com/rss")
+	ctx := context.Background()
+	if _, err := stores.Feeds.RecordFetchFailure(ctx, feed.ID, store.FetchFailure{At: time.Now(), HTTPStatus: 500, Error: "boom"}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := fmt.Sprintf(`{"Name": "Renamed", "Url": "http://example.com/rss", "UserID": %q, "ConsecutiveFailures": 0, "LastError": ""}`, feed.UserID.Hex())
+	req := mux.SetURLVars(httptest.NewRequest("PUT", "/v1/feeds/"+feed.ID.Hex(), strings.NewReader(body)), map[string]string{"id": feed.ID.Hex()})
+	rec := httptest.NewRecorder()
+	UpdateFeed(stores.Feeds)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	stored, err := stores.Feeds.Get(ctx, feed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// BSON keeps milliseconds
+	if stored.Name != "Renamed" || stored.CreatedAt.Sub(feed.CreatedAt).Abs() >= time.Millisecond {
+		t.Fatalf("update not applied or creation time lost: %+v", stored)
+	}
+	if stored.ConsecutiveFailures != 1 || stored.LastError != "boom" {
+		t.Fatalf("fetch health overwritten by the request: %+v", stored)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest("PUT", "/", strings.NewReader(body)), map[string]string{"id": primitive.NewObjectID().Hex()})
+	rec = httptest.NewRecorder()
+	UpdateFeed(stores.Feeds)(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("update of a missing feed got status %d, want 404", rec.Code)
+	}
+}
+
+// updateFeed PUTs body to the feed and returns the stored feed
+func updateFeed(t *testing.T, stores store.Stores, id primitive.ObjectID, body string) models.Feed {
+	t.Helper()
+	req := mux.SetURLVars(httptest.NewRequest("PUT", "/v1/feeds/"+id.Hex(), strings.NewReader(body)), map[string]string{"id": id.Hex()})
+	rec := httptest.NewRecorder()
+	UpdateFeed(stores.Feeds)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	stored, err := stores.Feeds.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestUpdateFeedClearsSettings(t *testing.T) {
+	stores := store.NewMemoryStores()
+	later := time.Now().Add(time.Hour)
+	feed := models.Feed{
+		ID: primitive.NewObjectID(), Name: "Test", Url: "http://example.com/rss", UserID: primitive.NewObjectID(), CreatedAt: time.Now(),
+		PollInterval: 3600, NextFetchAt: &later, Retention: &models.RetentionPolicy{MaxItems: 5},
+	}
+	if err := stores.Feeds.Create(context.Background(), feed); err != nil {
+		t.Fatal(err)
+	}
+	body := func(extra string) string {
+		return fmt.Sprintf(`{"Name": "Test", "Url": "http://example.com/rss", "UserID": %q%s}`, feed.UserID.Hex(), extra)
+	}
+
+	// The same interval keeps the schedule
+	stored := updateFeed(t, stores, feed.ID, body(`, "PollInterval": 3600, "Retention": {"MaxItems": 5}`))
+	if stored.NextFetchAt == nil || stored.NextFetchAt.Before(time.Now()) {
+		t.Fatalf("unchanged interval rescheduled the feed to %v", stored.NextFetchAt)
+	}
+
+	// Leaving the settings out clears them and reschedules the feed
+	stored = updateFeed(t, stores, feed.ID, body(""))
+	if stored.PollInterval != 0 || stored.Retention != nil {
+		t.Fatalf("settings not cleared: poll interval %d, retention %+v", stored.PollInterval, stored.Retention)
+	}
+	if stored.NextFetchAt == nil || stored.NextFetchAt.After(time.Now()) {
+		t.Fatalf("cleared interval not applied straight away: next fetch at %v", stored.NextFetchAt)
+	}
+}
+
+func TestUpdateFeedURLResetsFetchState(t *testing.T) {
+	stores := store.NewMemoryStores()
+	feed := createFeed(t, stores, "http://example.com/rss")
+	ctx := context.Background()
+	now := time.Now()
+	if err := stores.Feeds.RecordFetchSuccess(ctx, feed.ID, store.FetchSuccess{At: now, HTTPStatus: 200, ETag: `"v1"`, LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Feeds.RecordFetchFailure(ctx, feed.ID, store.FetchFailure{At: now, HTTPStatus: 404, Error: "gone"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Feeds.Pause(ctx, feed.ID, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renaming keeps what was learned about the URL
+	body := `{"Name": "Renamed", "Url": "http://example.com/rss", "UserID": "` + feed.UserID.Hex() + `"}`
+	stored := updateFeed(t, stores, feed.ID, body)
+	if stored.ETag != `"v1"` || stored.ConsecutiveFailures != 1 || !stored.Paused {
+		t.Fatalf("fetch state lost without a URL change: %+v", stored)
+	}
+
+	body = `{"Name": "Moved", "Url": "http://example.org/feed", "UserID": "` + feed.UserID.Hex() + `"}`
+	stored = updateFeed(t, stores, feed.ID, body)
+	if stored.ETag != "" || stored.LastModified != "" {
+		t.Errorf("validators of the old URL kept: %q, %q", stored.ETag, stored.LastModified)
+	}
+	if stored.ConsecutiveFailures != 0 || stored.LastError != "" || stored.LastHTTPStatus != 0 || stored.Paused || stored.PausedAt != nil {
+		t.Errorf("fetch health of the old URL kept: %+v", stored)
+	}
+	if stored.NextFetchAt == nil || stored.NextFetchAt.After(time.Now()) {
+		t.Errorf("new URL not fetched straight away: next fetch at %v", stored.NextFetchAt)
+	}
+}
+
+func TestDeleteFeedKeepsStarredItems(t *testing.T) {
+	stores := store.NewMemoryStores()
+	feed := createFeed(t, stores, "http://example.com/rss")
+	ctx := context.Background()
+	items := []models.FeedItem{
+		{ID: primitive.NewObjectID(), FeedID: feed.ID, IdentityKey: "guid:1", Title: "Kept", PubDate: time.Now()},
+		{ID: primitive.NewObjectID(), FeedID: feed.ID, IdentityKey: "guid:2", Title: "Deleted", PubDate: time.Now()},
+	}
+	if _, err := stores.Items.InsertNew(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Items.SetStarred(ctx, items[0].ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/v1/feeds/"+feed.ID.Hex(), nil), map[string]string{"id": feed.ID.Hex()})
+	rec := httptest.NewRecorder()
+	DeleteFeed(stores.Feeds, stores.Items, stores.Followers)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	if _, err := stores.Feeds.Get(ctx, feed.ID); err != store.ErrNotFound {
+		t.Fatalf("feed not deleted: %v", err)
+	}
+	if _, err := stores.Items.Get(ctx, items[0].ID); err != nil {
+		t.Errorf("starred item deleted with its feed: %v", err)
+	}
+	if _, err := stores.Items.Get(ctx, items[1].ID); err != store.ErrNotFound {
+		t.Errorf("unstarred item kept after its feed was deleted: %v", err)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	var uid string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid = r.Context().Value("user").(*db.UserClaims).UID
+	})
+
+	rec := httptest.NewRecorder()
+	AuthMiddleware(AuthConfig{Disabled: true})(next).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK || uid != "local" {
+		t.Fatalf("disabled authentication got status %d as user %q, want 200 as the local user", rec.Code, uid)
+	}
+
+	uid = ""
+	rec = httptest.NewRecorder()
+	AuthMiddleware(AuthConfig{})(next).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusInternalServerError || uid != "" {
+		t.Fatalf("authentication without a client got status %d as user %q, want 500", rec.Code, uid)
+	}
+}
@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore stores scrape jobs in the "scrape_jobs" collection
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoStore returns a job store kept in the given database
+func NewMongoStore(database *mongo.Database) *MongoStore {
+	return &MongoStore{collection: database.Collection("scrape_jobs")}
+}
+
+// EnsureIndexes creates the indexes the queue relies on: one pending job per
+// feed, fast claiming of the oldest queued job, and expiry of old jobs
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "pending_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "finished_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(Retention.Seconds())),
+		},
+	})
+	return err
+}
+
+func (s *MongoStore) Enqueue(ctx context.Context, feedID primitive.ObjectID) (job *models.ScrapeJob, coalesced bool, err error) {
+	newID := primitive.NewObjectID()
+	filter := bson.M{"pending_key": feedID.Hex()}
+	update := bson.M{"$setOnInsert": bson.M{
+		"_id":        newID,
+		"feed_id":    feedID,
+		"status":     models.JobQueued,
+		"created_at": time.Now(),
+		"attempts":   0,
+		"new_items":  0,
+	}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	// Two concurrent upserts can race on the unique index; the loser simply
+	// finds the winner's job on retry
+	for attempt := 0; attempt < 2; attempt++ {
+		var stored models.ScrapeJob
+		err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&stored)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return &stored, stored.ID != newID, nil
+	}
+	return nil, false, err
+}
+
+func (s *MongoStore) Get(ctx context.Context, id primitive.ObjectID) (*models.ScrapeJob, error) {
+	var job models.ScrapeJob
+	if err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *MongoStore) Claim(ctx context.Context, worker string, staleAfter time.Duration) (*models.ScrapeJob, error) {
+	now := time.Now()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.JobQueued},
+		bson.M{"status": models.JobRunning, "started_at": bson.M{"$lt": now.Add(-staleAfter)}},
+	}}
+	update := bson.M{
+		"$set": bson.M{"status": models.JobRunning, "started_at": now, "worker": worker},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.ScrapeJob
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *MongoStore) Complete(ctx context.Context, job *models.ScrapeJob, newItems int, errMsg, errCode string) error {
+	status := models.JobSucceeded
+	if errMsg != "" {
+		status = models.JobFailed
+	}
+	set := bson.M{
+		"status":      status,
+		"finished_at": time.Now(),
+		"new_items":   newItems,
+	}
+	if errMsg != "" {
+		set["error"] = errMsg
+		set["error_code"] = errCode
+	}
+
+	// Only the worker that currently owns the job may finish it
+	_, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": job.ID, "worker": job.Worker, "status": models.JobRunning},
+		bson.M{"$set": set, "$unset": bson.M{"pending_key": ""}},
+	)
+	return err
+}
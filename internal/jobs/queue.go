@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Retention is how long finished jobs are kept before they are expired
+const Retention = 7 * 24 * time.Hour
+
+// Store persists scrape jobs for a Queue
+type Store interface {
+	// Enqueue adds a queued job for a feed. If the feed already has a
+	// queued or running job, that job is returned instead and coalesced is
+	// true.
+	Enqueue(ctx context.Context, feedID primitive.ObjectID) (job *models.ScrapeJob, coalesced bool, err error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.ScrapeJob, error)
+	// Claim marks the oldest queued job as running for worker and returns
+	// it. Running jobs not finished within staleAfter are reclaimed, so a
+	// crashed worker does not strand a feed. It returns nil when there is
+	// no work.
+	Claim(ctx context.Context, worker string, staleAfter time.Duration) (*models.ScrapeJob, error)
+	// Complete records the outcome of a job still owned by its worker and
+	// frees its feed for new jobs
+	Complete(ctx context.Context, job *models.ScrapeJob, newItems int, errMsg, errCode string) error
+}
+
+// Queue hands scrape jobs from the API to the workers
+type Queue struct {
+	store Store
+	wake  chan struct{}
+}
+
+// NewQueue returns a queue backed by the given store
+func NewQueue(store Store) *Queue {
+	return &Queue{
+		store: store,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds a scrape job for a feed. If the feed already has a queued or
+// running job, that job is returned instead and coalesced is true.
+func (q *Queue) Enqueue(ctx context.Context, feedID primitive.ObjectID) (*models.ScrapeJob, bool, error) {
+	job, coalesced, err := q.store.Enqueue(ctx, feedID)
+	if err == nil {
+		q.notify()
+	}
+	return job, coalesced, err
+}
+
+// Get returns a job by ID
+func (q *Queue) Get(ctx context.Context, id primitive.ObjectID) (*models.ScrapeJob, error) {
+	return q.store.Get(ctx, id)
+}
+
+// notify wakes an idle worker in this process
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often idle workers look for jobs enqueued by other
+// replicas; jobs enqueued by this process wake a worker immediately
+const pollInterval = 2 * time.Second
+
+// ScrapeFunc scrapes a feed and returns how many new items it stored
+type ScrapeFunc func(ctx context.Context, feedID string) (int, error)
+
+// Config controls the job workers
+type Config struct {
+	Workers    int           // jobs processed in parallel by this process
+	JobTimeout time.Duration // deadline for a single job
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Workers:    2,
+		JobTimeout: 2 * time.Minute,
+	}
+}
+
+// Process runs workers that claim and execute queued jobs until ctx is
+// cancelled, then returns once the jobs already claimed have finished.
+// errorCode classifies a failed scrape for the job record.
+func (q *Queue) Process(ctx context.Context, cfg Config, scrape ScrapeFunc, errorCode func(error) string) {
+	host, _ := os.Hostname()
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		worker := fmt.Sprintf("%s-%d-%d", host, os.Getpid(), i)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, cfg, worker, scrape, errorCode)
+		}()
+	}
+	wg.Wait()
+}
+
+// work is the loop of a single worker
+func (q *Queue) work(ctx context.Context, cfg Config, worker string, scrape ScrapeFunc, errorCode func(error) string) {
+	// A job still running after twice its deadline has lost its worker
+	staleAfter := 2 * cfg.JobTimeout
+
+	for ctx.Err() == nil {
+		job, err := q.store.Claim(ctx, worker, staleAfter)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to claim scrape job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-q.wake:
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		log.Printf("Running scrape job %s for feed %s", job.ID.Hex(), job.FeedID.Hex())
+		// Finish a claimed job even when shutting down, bounded by its deadline
+		jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.JobTimeout)
+		newItems, scrapeErr := scrape(jobCtx, job.FeedID.Hex())
+		cancel()
+
+		var errMsg, errCode string
+		if scrapeErr != nil {
+			errMsg = scrapeErr.Error()
+			errCode = errorCode(scrapeErr)
+			log.Printf("Scrape job %s failed: %v", job.ID.Hex(), scrapeErr)
+		} else {
+			log.Printf("Scrape job %s added %d new items", job.ID.Hex(), newItems)
+		}
+
+		// Record the outcome even when shutting down
+		completeCtx, cancelComplete := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := q.store.Complete(completeCtx, job, newItems, errMsg, errCode); err != nil {
+			log.Printf("Failed to complete scrape job %s: %v", job.ID.Hex(), err)
+		}
+		cancelComplete()
+	}
+}
@@ -0,0 +1,127 @@
+// Package lease runs a task on one instance at a time, guarded by a named
+// lease kept in the database the instances share.
+package lease
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Lease is a named lock. At most one holder owns it at a time; the owner
+// must renew it before its TTL runs out or any other instance may take it
+// over.
+type Lease interface {
+	Name() string
+	// Holder identifies this process as a lease holder
+	Holder() string
+	TTL() time.Duration
+	// TryAcquire takes the lease if it is free or expired, or extends it if
+	// this process already holds it. It reports whether the lease is now
+	// held.
+	TryAcquire(ctx context.Context) (bool, error)
+	// Release gives up the lease if this process holds it, so another
+	// instance can take over without waiting for it to expire
+	Release(ctx context.Context) error
+}
+
+// RunWhileLeader campaigns for the lease until ctx is cancelled. Whenever
+// this process holds it, fn runs with a context that is cancelled as soon as
+// the lease is lost or can no longer be renewed in time.
+func RunWhileLeader(ctx context.Context, l Lease, fn func(context.Context)) {
+	interval := l.TTL() / 3
+	for {
+		acquired, err := tryAcquire(ctx, l)
+		if err != nil {
+			log.Printf("Failed to acquire lease %s: %v", l.Name(), err)
+		}
+		if acquired {
+			log.Printf("Acquired lease %s as %s", l.Name(), l.Holder())
+			lead(ctx, l, fn, interval)
+			log.Printf("No longer holding lease %s", l.Name())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// lead runs fn while renewing the lease every interval
+func lead(ctx context.Context, l Lease, fn func(context.Context), interval time.Duration) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(leaderCtx)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastRenewed := time.Now()
+
+	for {
+		select {
+		case <-done:
+			release(l)
+			return
+		case <-ctx.Done():
+			cancel()
+			<-done
+			release(l)
+			return
+		case <-ticker.C:
+			held, err := tryAcquire(ctx, l)
+			switch {
+			case err == nil && held:
+				lastRenewed = time.Now()
+			case err == nil:
+				log.Printf("Lease %s was taken over by another instance", l.Name())
+				cancel()
+				<-done
+				return
+			case time.Since(lastRenewed) >= l.TTL()-interval:
+				// Stop before the lease can expire under us
+				log.Printf("Could not renew lease %s in time: %v", l.Name(), err)
+				cancel()
+				<-done
+				return
+			default:
+				log.Printf("Failed to renew lease %s, will retry: %v", l.Name(), err)
+			}
+		}
+	}
+}
+
+func tryAcquire(ctx context.Context, l Lease) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return l.TryAcquire(ctx)
+}
+
+func release(l Lease) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := l.Release(ctx); err != nil {
+		log.Printf("Failed to release lease %s: %v", l.Name(), err)
+	}
+}
+
+// HolderID builds an identifier unique to this process
+func HolderID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
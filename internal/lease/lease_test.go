@@ -0,0 +1,140 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// leaseTable stands in for the database row the instances share
+type leaseTable struct {
+	mu          sync.Mutex
+	holder      string
+	expiresAt   time.Time
+	unreachable map[string]bool
+}
+
+type fakeLease struct {
+	table  *leaseTable
+	holder string
+	ttl    time.Duration
+}
+
+func (l *fakeLease) Name() string       { return "test" }
+func (l *fakeLease) Holder() string     { return l.holder }
+func (l *fakeLease) TTL() time.Duration { return l.ttl }
+
+func (l *fakeLease) TryAcquire(ctx context.Context) (bool, error) {
+	l.table.mu.Lock()
+	defer l.table.mu.Unlock()
+	if l.table.unreachable[l.holder] {
+		return false, errors.New("database unreachable")
+	}
+	now := time.Now()
+	if l.table.holder != "" && l.table.holder != l.holder && now.Before(l.table.expiresAt) {
+		return false, nil
+	}
+	l.table.holder = l.holder
+	l.table.expiresAt = now.Add(l.ttl)
+	return true, nil
+}
+
+func (l *fakeLease) Release(ctx context.Context) error {
+	l.table.mu.Lock()
+	defer l.table.mu.Unlock()
+	if l.table.holder == l.holder {
+		l.table.holder = ""
+	}
+	return nil
+}
+
+// instance runs RunWhileLeader for one holder and reports when its task
+// starts and stops
+type instance struct {
+	started chan struct{}
+	stopped chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func startInstance(t *testing.T, table *leaseTable, holder string) *instance {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &instance{started: make(chan struct{}, 10), stopped: make(chan struct{}, 10), cancel: cancel, done: make(chan struct{})}
+	l := &fakeLease{table: table, holder: holder, ttl: 150 * time.Millisecond}
+	go func() {
+		defer close(i.done)
+		RunWhileLeader(ctx, l, func(ctx context.Context) {
+			i.started <- struct{}{}
+			<-ctx.Done()
+			i.stopped <- struct{}{}
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-i.done
+	})
+	return i
+}
+
+func expect(t *testing.T, ch chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func expectNot(t *testing.T, ch chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+		t.Fatalf("unexpected %s", what)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestRunWhileLeaderHandsOverOnShutdown(t *testing.T) {
+	table := &leaseTable{}
+	a := startInstance(t, table, "a")
+	expect(t, a.started, "a to lead")
+	b := startInstance(t, table, "b")
+	expectNot(t, b.started, "b leading while a renews")
+
+	// A clean shutdown releases the lease rather than letting it expire
+	a.cancel()
+	expect(t, a.stopped, "a to stop")
+	expect(t, b.started, "b to take over")
+}
+
+func TestRunWhileLeaderStopsWhenRenewalFails(t *testing.T) {
+	table := &leaseTable{unreachable: make(map[string]bool)}
+	a := startInstance(t, table, "a")
+	expect(t, a.started, "a to lead")
+	b := startInstance(t, table, "b")
+
+	table.mu.Lock()
+	table.unreachable["a"] = true
+	table.mu.Unlock()
+
+	// a cannot release the lease, so b takes over once it expires
+	expect(t, a.stopped, "a to stop")
+	expect(t, b.started, "b to take over")
+}
+
+func TestRunWhileLeaderStopsWhenTakenOver(t *testing.T) {
+	table := &leaseTable{}
+	a := startInstance(t, table, "a")
+	expect(t, a.started, "a to lead")
+
+	table.mu.Lock()
+	table.holder = "b"
+	table.expiresAt = time.Now().Add(time.Hour)
+	table.mu.Unlock()
+
+	expect(t, a.stopped, "a to stop")
+	expectNot(t, a.started, "a leading again while b holds the lease")
+}
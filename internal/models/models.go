@@ -24,16 +24,95 @@ type Feed struct {
     UserID    primitive.ObjectID `bson:"user_id" validate:"required"`
     CreatedAt time.Time          `bson:"created_at" validate:"required"`
     UpdatedAt time.Time          `bson:"updated_at" validate:"required"`
+
+    // Scheduling. PollInterval is in seconds and, when set, fixes how often
+    // the feed is polled; otherwise the scheduler adapts CurrentInterval to
+    // how often the feed publishes.
+    PollInterval    int           `bson:"poll_interval,omitempty"`
+    CurrentInterval int           `bson:"current_interval,omitempty"`
+    NextFetchAt     *time.Time    `bson:"next_fetch_at,omitempty"`
+    Hints           *PollingHints `bson:"hints,omitempty"`
+
+    // Validators from the last successful fetch, sent back as
+    // If-None-Match/If-Modified-Since on the next one
+    ETag         string `bson:"etag,omitempty"`
+    LastModified string `bson:"last_modified,omitempty"`
+
+    // Fetch health, maintained by the scraper. The error fields describe the
+    // most recent failed fetch and are cleared on the next success.
+    LastAttemptAt       *time.Time `bson:"last_attempt_at,omitempty"`
+    LastSuccessAt       *time.Time `bson:"last_success_at,omitempty"`
+    LastHTTPStatus      int        `bson:"last_http_status,omitempty"`
+    ConsecutiveFailures int        `bson:"consecutive_failures,omitempty"`
+    LastError           string     `bson:"last_error,omitempty"`
+    LastErrorCode       string     `bson:"last_error_code,omitempty"`
+    LastErrorAt         *time.Time `bson:"last_error_at,omitempty"`
+    Paused              bool       `bson:"paused,omitempty"`
+    PausedAt            *time.Time `bson:"paused_at,omitempty"`
+
+    // Retention overrides the global item retention policy for this feed
+    Retention *RetentionPolicy `bson:"retention,omitempty"`
+}
+
+// RetentionPolicy limits how many items of a feed are kept. Zero fields
+// fall back to the global default; starred items are never pruned.
+type RetentionPolicy struct {
+    MaxAge   int `bson:"max_age,omitempty"`   // seconds since publication
+    MaxItems int `bson:"max_items,omitempty"` // newest unstarred items kept
+}
+
+// PollingHints are the publisher's polling suggestions from the last fetch
+type PollingHints struct {
+    MinInterval int      `bson:"min_interval,omitempty"` // seconds, from <ttl> and sy:updatePeriod/updateFrequency
+    SkipHours   []int    `bson:"skip_hours,omitempty"`   // UTC hours
+    SkipDays    []string `bson:"skip_days,omitempty"`    // weekday names
 }
 
 // FeedItem represents an item in an RSS feed
 type FeedItem struct {
     ID          primitive.ObjectID `bson:"_id,omitempty" validate:"required"`
     FeedID      primitive.ObjectID `bson:"feed_id" validate:"required"`
+    GUID        string             `bson:"guid,omitempty"`
+    IdentityKey string             `bson:"identity_key"` // GUID, else normalized link, else content hash
     Title       string             `bson:"title" validate:"required"`
     Link        string             `bson:"link" validate:"required"`
     Description string             `bson:"description"`
     PubDate     time.Time          `bson:"pub_date" validate:"required"`
+    Author      string             `bson:"author,omitempty"`
+    Categories  []string           `bson:"categories,omitempty"`
+    Enclosures  []Enclosure        `bson:"enclosures,omitempty"`
+    ContentHash string             `bson:"content_hash,omitempty"`
+    // Revision counts the edits seen since the item was first stored
+    Revision  int        `bson:"revision,omitempty"`
+    UpdatedAt *time.Time `bson:"updated_at,omitempty"`
+    // Starred items are exempt from retention pruning
+    Starred bool `bson:"starred,omitempty"`
+}
+
+// ItemRevision is a copy of a feed item's content as it was before the
+// publisher edited it. Revisions are stored in the "item_revisions"
+// collection.
+type ItemRevision struct {
+    ID          primitive.ObjectID `bson:"_id,omitempty"`
+    ItemID      primitive.ObjectID `bson:"item_id" validate:"required"`
+    FeedID      primitive.ObjectID `bson:"feed_id" validate:"required"`
+    Revision    int                `bson:"revision"`
+    Title       string             `bson:"title"`
+    Link        string             `bson:"link"`
+    Description string             `bson:"description"`
+    Author      string             `bson:"author,omitempty"`
+    Categories  []string           `bson:"categories,omitempty"`
+    Enclosures  []Enclosure        `bson:"enclosures,omitempty"`
+    ContentHash string             `bson:"content_hash"`
+    // ReplacedAt is when the edit that superseded this content was seen
+    ReplacedAt time.Time `bson:"replaced_at"`
+}
+
+// Enclosure represents a media attachment of a feed item
+type Enclosure struct {
+    URL    string `bson:"url"`
+    Type   string `bson:"type,omitempty"`
+    Length int64  `bson:"length,omitempty"`
 }
 
 // FeedFollower represents a user's subscription to a feed
@@ -42,4 +121,50 @@ type FeedFollower struct {
     FeedID    primitive.ObjectID `bson:"feed_id" validate:"required"`
     UserID    string             `bson:"user_id" validate:"required"` // Changed to string for Firebase UID
     CreatedAt time.Time          `bson:"created_at" validate:"required"`
+}
+
+// ScrapeRun records a single execution of the scraper against a feed
+type ScrapeRun struct {
+    ID          primitive.ObjectID `bson:"_id,omitempty"`
+    FeedID      primitive.ObjectID `bson:"feed_id" validate:"required"`
+    StartedAt   time.Time          `bson:"started_at" validate:"required"`
+    FinishedAt  time.Time          `bson:"finished_at"`
+    DurationMs  int64              `bson:"duration_ms"`
+    HTTPStatus  int                `bson:"http_status,omitempty"`
+    NotModified bool               `bson:"not_modified,omitempty"`
+    Bytes       int64              `bson:"bytes"`
+    ItemsParsed int                `bson:"items_parsed"`
+    ItemsNew    int                `bson:"items_new"`
+    // Items whose date could not be parsed; they are stored with the time
+    // they were first seen instead
+    ItemsBadDate int    `bson:"items_bad_date"`
+    ItemsUpdated int    `bson:"items_updated"`
+    Error        string `bson:"error,omitempty"`
+    ErrorCode    string `bson:"error_code,omitempty"`
+}
+
+// Scrape job states
+const (
+    JobQueued    = "queued"
+    JobRunning   = "running"
+    JobSucceeded = "succeeded"
+    JobFailed    = "failed"
+)
+
+// ScrapeJob is a queued request to scrape a feed
+type ScrapeJob struct {
+    ID         primitive.ObjectID `bson:"_id,omitempty"`
+    FeedID     primitive.ObjectID `bson:"feed_id" validate:"required"`
+    Status     string             `bson:"status" validate:"required"`
+    CreatedAt  time.Time          `bson:"created_at" validate:"required"`
+    StartedAt  *time.Time         `bson:"started_at,omitempty"`
+    FinishedAt *time.Time         `bson:"finished_at,omitempty"`
+    Attempts   int                `bson:"attempts"`
+    NewItems   int                `bson:"new_items"`
+    Error      string             `bson:"error,omitempty"`
+    ErrorCode  string             `bson:"error_code,omitempty"`
+    Worker     string             `bson:"worker,omitempty"`
+    // Set while the job is queued or running, so a unique index lets only
+    // one pending job exist per feed
+    PendingKey string `bson:"pending_key,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,104 @@
+package retention
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// deleteBatch bounds how many item IDs go into a single delete
+const deleteBatch = 1000
+
+// Cumulative janitor metrics, published at /debug/vars
+var metrics = expvar.NewMap("retention")
+
+// Janitor periodically prunes items that fall outside their feed's
+// retention policy and items left behind by deleted feeds
+type Janitor struct {
+	feeds  store.FeedStore
+	items  store.ItemStore
+	config Config
+}
+
+// NewJanitor creates a Janitor applying the given configuration
+func NewJanitor(feeds store.FeedStore, items store.ItemStore, cfg Config) *Janitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultConfig().Interval
+	}
+	return &Janitor{feeds: feeds, items: items, config: cfg}
+}
+
+// Run prunes once straight away and then every Interval until ctx is cancelled
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := j.Prune(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Retention pass failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune deletes everything a Preview of all feeds lists and returns the
+// number of items deleted
+func (j *Janitor) Prune(ctx context.Context) (int64, error) {
+	start := time.Now()
+	plan, err := Preview(ctx, j.feeds, j.items, j.config, primitive.NilObjectID)
+	if err != nil {
+		return 0, err
+	}
+
+	var pruned int64
+	for _, feedPlan := range plan.Feeds {
+		deleted, err := j.deleteItems(ctx, feedPlan.ItemIDs)
+		pruned += deleted
+		if err != nil {
+			return pruned, err
+		}
+		if deleted > 0 {
+			log.Printf("Pruned %d items of feed %s", deleted, feedPlan.Feed.ID.Hex())
+		}
+	}
+
+	var orphans int64
+	for _, feedID := range plan.OrphanedFeeds {
+		deleted, err := j.items.DeleteByFeed(ctx, feedID)
+		orphans += deleted
+		if err != nil {
+			return pruned + orphans, err
+		}
+	}
+
+	metrics.Add("passes", 1)
+	metrics.Add("items_pruned", pruned)
+	metrics.Add("orphans_pruned", orphans)
+	if pruned > 0 || orphans > 0 {
+		log.Printf("Retention pass finished in %v: %d items pruned, %d orphaned items of %d deleted feeds removed",
+			time.Since(start), pruned, orphans, len(plan.OrphanedFeeds))
+	}
+	return pruned + orphans, nil
+}
+
+// deleteItems removes the given items and their revision history in
+// batches, sparing any starred since the plan was made
+func (j *Janitor) deleteItems(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	var deleted int64
+	for start := 0; start < len(ids); start += deleteBatch {
+		end := min(start+deleteBatch, len(ids))
+		n, err := j.items.Delete(ctx, ids[start:end])
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
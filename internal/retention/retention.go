@@ -0,0 +1,158 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Policy limits how many items of a feed are kept. A zero field means no
+// limit of that kind.
+type Policy struct {
+	MaxAge   time.Duration // items published longer ago than this are pruned
+	MaxItems int           // only this many of the newest items are kept
+}
+
+// Config holds the global default policy and how often the janitor runs
+type Config struct {
+	Default  Policy
+	Interval time.Duration // time between pruning passes
+	LeaseTTL time.Duration // how long the janitor lease outlives its last renewal
+}
+
+// DefaultConfig returns the settings used when nothing is configured. Items
+// are kept forever unless a limit is set globally or on the feed.
+func DefaultConfig() Config {
+	return Config{
+		Interval: time.Hour,
+		LeaseTTL: 30 * time.Second,
+	}
+}
+
+// PolicyFor returns the policy applying to a feed: its own limits where it
+// sets them, the defaults otherwise
+func PolicyFor(feed models.Feed, defaults Policy) Policy {
+	policy := defaults
+	if feed.Retention != nil {
+		if feed.Retention.MaxAge > 0 {
+			policy.MaxAge = time.Duration(feed.Retention.MaxAge) * time.Second
+		}
+		if feed.Retention.MaxItems > 0 {
+			policy.MaxItems = feed.Retention.MaxItems
+		}
+	}
+	return policy
+}
+
+// FeedPlan lists the items of one feed that its policy would prune
+type FeedPlan struct {
+	Feed    models.Feed
+	Policy  Policy
+	ByAge   int // items older than MaxAge
+	ByCount int // items beyond the newest MaxItems
+	ItemIDs []primitive.ObjectID
+}
+
+// Plan is what a pruning pass would delete
+type Plan struct {
+	Feeds []FeedPlan
+	// Feeds that no longer exist but still have items stored
+	OrphanedFeeds []primitive.ObjectID
+	OrphanedItems int64 // unstarred items of OrphanedFeeds, which are pruned
+}
+
+// Preview works out what a pruning pass would delete without deleting
+// anything. With a non-zero feedID only that feed is considered and
+// orphaned items are left out.
+func Preview(ctx context.Context, feeds store.FeedStore, items store.ItemStore, cfg Config, feedID primitive.ObjectID) (*Plan, error) {
+	// Read which feeds have items before listing the feeds. A feed is
+	// created before its items are stored, so one created in between is
+	// either listed or has no items here, and is never taken for orphaned.
+	var referenced []primitive.ObjectID
+	if feedID.IsZero() {
+		var err error
+		referenced, err = items.FeedIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var candidates []models.Feed
+	if feedID.IsZero() {
+		all, err := feeds.List(ctx, store.FeedFilter{})
+		if err != nil {
+			return nil, err
+		}
+		candidates = all
+	} else {
+		feed, err := feeds.Get(ctx, feedID)
+		if err != nil {
+			return nil, err
+		}
+		candidates = []models.Feed{feed}
+	}
+
+	plan := &Plan{}
+	now := time.Now()
+	for _, feed := range candidates {
+		feedPlan, err := planFeed(ctx, items, feed, PolicyFor(feed, cfg.Default), now)
+		if err != nil {
+			return nil, err
+		}
+		plan.Feeds = append(plan.Feeds, feedPlan)
+	}
+
+	if feedID.IsZero() {
+		exists := make(map[primitive.ObjectID]bool, len(candidates))
+		for _, feed := range candidates {
+			exists[feed.ID] = true
+		}
+		for _, id := range referenced {
+			if !exists[id] {
+				plan.OrphanedFeeds = append(plan.OrphanedFeeds, id)
+			}
+		}
+		var err error
+		plan.OrphanedItems, err = items.CountByFeeds(ctx, plan.OrphanedFeeds)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// planFeed finds the unstarred items of a feed that fall outside its policy
+func planFeed(ctx context.Context, items store.ItemStore, feed models.Feed, policy Policy, now time.Time) (FeedPlan, error) {
+	plan := FeedPlan{Feed: feed, Policy: policy}
+	prune := make(map[primitive.ObjectID]bool)
+
+	if policy.MaxAge > 0 {
+		ids, err := items.PrunableByAge(ctx, feed.ID, now.Add(-policy.MaxAge))
+		if err != nil {
+			return plan, err
+		}
+		plan.ByAge = len(ids)
+		for _, id := range ids {
+			prune[id] = true
+		}
+	}
+
+	if policy.MaxItems > 0 {
+		ids, err := items.PrunableByCount(ctx, feed.ID, policy.MaxItems)
+		if err != nil {
+			return plan, err
+		}
+		plan.ByCount = len(ids)
+		for _, id := range ids {
+			prune[id] = true
+		}
+	}
+
+	for id := range prune {
+		plan.ItemIDs = append(plan.ItemIDs, id)
+	}
+	return plan, nil
+}
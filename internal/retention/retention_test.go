@@ -0,0 +1,218 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPolicyFor(t *testing.T) {
+	defaults := Policy{MaxAge: 24 * time.Hour, MaxItems: 100}
+	tests := []struct {
+		name      string
+		retention *models.RetentionPolicy
+		want      Policy
+	}{
+		{"no override", nil, defaults},
+		{"age override", &models.RetentionPolicy{MaxAge: 3600}, Policy{MaxAge: time.Hour, MaxItems: 100}},
+		{"count override", &models.RetentionPolicy{MaxItems: 5}, Policy{MaxAge: 24 * time.Hour, MaxItems: 5}},
+		{"zero fields fall back", &models.RetentionPolicy{}, defaults},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PolicyFor(models.Feed{Retention: tt.retention}, defaults); got != tt.want {
+				t.Errorf("PolicyFor = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// addFeed stores a feed with items published the given ages ago
+func addFeed(t *testing.T, stores store.Stores, ages ...time.Duration) (models.Feed, []models.FeedItem) {
+	t.Helper()
+	ctx := context.Background()
+	feed := models.Feed{ID: primitive.NewObjectID(), Name: "Test", CreatedAt: time.Now()}
+	if err := stores.Feeds.Create(ctx, feed); err != nil {
+		t.Fatal(err)
+	}
+	return feed, addItems(t, stores, feed.ID, ages...)
+}
+
+func addItems(t *testing.T, stores store.Stores, feedID primitive.ObjectID, ages ...time.Duration) []models.FeedItem {
+	t.Helper()
+	var items []models.FeedItem
+	for _, age := range ages {
+		id := primitive.NewObjectID()
+		items = append(items, models.FeedItem{
+			ID:          id,
+			FeedID:      feedID,
+			IdentityKey: "guid:" + id.Hex(),
+			Title:       "Item",
+			PubDate:     time.Now().Add(-age),
+		})
+	}
+	if _, err := stores.Items.InsertNew(context.Background(), items); err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+// addRevision records an earlier version of item
+func addRevision(t *testing.T, stores store.Stores, item models.FeedItem) {
+	t.Helper()
+	updated := item
+	updated.Title = "Edited"
+	updated.ContentHash = "edited"
+	revision := models.ItemRevision{ID: primitive.NewObjectID(), ItemID: item.ID, FeedID: item.FeedID, Title: item.Title, ReplacedAt: time.Now()}
+	if applied, err := stores.Items.ApplyEdit(context.Background(), item, updated, revision); err != nil || !applied {
+		t.Fatalf("ApplyEdit = %v, %v", applied, err)
+	}
+}
+
+func star(t *testing.T, stores store.Stores, item models.FeedItem) {
+	t.Helper()
+	if err := stores.Items.SetStarred(context.Background(), item.ID, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(t *testing.T, stores store.Stores, item models.FeedItem) bool {
+	t.Helper()
+	_, err := stores.Items.Get(context.Background(), item.ID)
+	return err == nil
+}
+
+func revisionCount(t *testing.T, stores store.Stores, item models.FeedItem) int {
+	t.Helper()
+	revisions, err := stores.Items.Revisions(context.Background(), item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(revisions)
+}
+
+func TestPreview(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name             string
+		policy           Policy
+		wantAge, wantCnt int
+		wantIDs          int
+	}{
+		{"no limits", Policy{}, 0, 0, 0},
+		{"by age", Policy{MaxAge: 7 * day}, 1, 0, 1},
+		{"by count", Policy{MaxItems: 1}, 0, 2, 2},
+		{"overlapping limits", Policy{MaxAge: 7 * day, MaxItems: 2}, 1, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores := store.NewMemoryStores()
+			feed, items := addFeed(t, stores, time.Hour, 2*day, 10*day, 20*day)
+			// Starred items are never counted
+			star(t, stores, items[3])
+
+			plan, err := Preview(context.Background(), stores.Feeds, stores.Items, Config{Default: tt.policy}, feed.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.Feeds) != 1 {
+				t.Fatalf("got %d feed plans, want 1", len(plan.Feeds))
+			}
+			got := plan.Feeds[0]
+			if got.ByAge != tt.wantAge || got.ByCount != tt.wantCnt || len(got.ItemIDs) != tt.wantIDs {
+				t.Errorf("got by age %d, by count %d, %d items; want %d, %d, %d",
+					got.ByAge, got.ByCount, len(got.ItemIDs), tt.wantAge, tt.wantCnt, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestPruneSparesStarredItems(t *testing.T) {
+	stores := store.NewMemoryStores()
+	ctx := context.Background()
+	_, items := addFeed(t, stores, time.Hour, 10*24*time.Hour, 20*24*time.Hour)
+	old, starred := items[1], items[2]
+	addRevision(t, stores, old)
+	star(t, stores, starred)
+	addRevision(t, stores, starred)
+
+	// Items left behind by a deleted feed
+	orphanFeed := primitive.NewObjectID()
+	orphans := addItems(t, stores, orphanFeed, time.Hour, time.Hour)
+	addRevision(t, stores, orphans[0])
+	star(t, stores, orphans[1])
+	addRevision(t, stores, orphans[1])
+
+	cfg := Config{Default: Policy{MaxAge: 7 * 24 * time.Hour}}
+	plan, err := Preview(ctx, stores.Feeds, stores.Items, cfg, primitive.NilObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.OrphanedFeeds) != 1 || plan.OrphanedItems != 1 {
+		t.Errorf("preview found %d orphaned items of %d feeds, want 1 of 1", plan.OrphanedItems, len(plan.OrphanedFeeds))
+	}
+
+	janitor := NewJanitor(stores.Feeds, stores.Items, cfg)
+	pruned, err := janitor.Prune(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 2 {
+		t.Errorf("pruned %d items, want 2", pruned)
+	}
+
+	for _, tt := range []struct {
+		name          string
+		item          models.FeedItem
+		kept          bool
+		wantRevisions int
+	}{
+		{"recent item", items[0], true, 0},
+		{"expired item", old, false, 0},
+		{"starred expired item", starred, true, 1},
+		{"orphaned item", orphans[0], false, 0},
+		{"starred orphaned item", orphans[1], true, 1},
+	} {
+		if got := exists(t, stores, tt.item); got != tt.kept {
+			t.Errorf("%s: kept = %v, want %v", tt.name, got, tt.kept)
+		}
+		if got := revisionCount(t, stores, tt.item); got != tt.wantRevisions {
+			t.Errorf("%s: %d revisions, want %d", tt.name, got, tt.wantRevisions)
+		}
+	}
+}
+
+// racingItems creates a feed with items right after FeedIDs is read, as a
+// scrape of a newly created feed could
+type racingItems struct {
+	store.ItemStore
+	race func()
+}
+
+func (r racingItems) FeedIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	ids, err := r.ItemStore.FeedIDs(ctx)
+	if r.race != nil {
+		r.race()
+	}
+	return ids, err
+}
+
+func TestPruneKeepsFeedsCreatedDuringThePass(t *testing.T) {
+	stores := store.NewMemoryStores()
+	var created []models.FeedItem
+	items := racingItems{ItemStore: stores.Items, race: func() {
+		_, created = addFeed(t, stores, time.Hour)
+	}}
+
+	janitor := NewJanitor(stores.Feeds, items, Config{})
+	if _, err := janitor.Prune(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || !exists(t, stores, created[0]) {
+		t.Fatal("items of a feed created during the pass were pruned as orphans")
+	}
+}
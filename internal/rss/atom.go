@@ -0,0 +1,146 @@
+package rss
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// AtomFeed represents the structure of an Atom 1.0 feed
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []AtomLink  `xml:"link"`
+	Entries []AtomEntry `xml:"entry"`
+	SyndicationHints
+}
+
+// AtomEntry represents a single entry in an Atom feed
+type AtomEntry struct {
+	Title      AtomText       `xml:"title"`
+	ID         string         `xml:"id"`
+	Links      []AtomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    AtomText       `xml:"summary"`
+	Content    AtomText       `xml:"content"`
+	Authors    []AtomPerson   `xml:"author"`
+	Categories []AtomCategory `xml:"category"`
+}
+
+// AtomPerson is an Atom <author> or <contributor> element
+type AtomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+// AtomCategory is an Atom <category> element
+type AtomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+// AtomLink is an Atom <link> element
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// AtomText is an Atom text construct such as <title>, <summary> or <content>
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// String returns the text content, keeping markup for xhtml constructs
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// parseAtom decodes an Atom document and maps its entries to items
+func parseAtom(data []byte) (*Feed, error) {
+	var feed AtomFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(feed.Entries))
+	for _, entry := range feed.Entries {
+		items = append(items, entry.toItem())
+	}
+	return &Feed{Items: items, Hints: feed.SyndicationHints.pollingHints()}, nil
+}
+
+// toItem maps an Atom entry onto the common Item type
+func (e AtomEntry) toItem() Item {
+	description := e.Summary.String()
+	if description == "" {
+		description = e.Content.String()
+	}
+
+	pubDate := strings.TrimSpace(e.Published)
+	if pubDate == "" {
+		pubDate = strings.TrimSpace(e.Updated)
+	}
+
+	var authors []string
+	for _, author := range e.Authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			authors = append(authors, name)
+		}
+	}
+
+	var categories []string
+	for _, category := range e.Categories {
+		if category.Term != "" {
+			categories = append(categories, category.Term)
+		}
+	}
+
+	var enclosures []Enclosure
+	for _, link := range e.Links {
+		if link.Rel == "enclosure" {
+			enclosures = append(enclosures, Enclosure{URL: link.Href, Type: link.Type, Length: link.Length})
+		}
+	}
+
+	return Item{
+		Title:       e.Title.String(),
+		Link:        alternateLink(e.Links),
+		Description: description,
+		PubDate:     pubDate,
+		GUID:        strings.TrimSpace(e.ID),
+		Author:      strings.Join(authors, ", "),
+		Categories:  categories,
+		Enclosures:  enclosures,
+	}
+}
+
+// alternateLink picks the entry's permalink. Atom treats a link without a
+// rel attribute as rel="alternate"; an HTML alternate is preferred when an
+// entry has several.
+func alternateLink(links []AtomLink) string {
+	var fallback string
+	for _, link := range links {
+		if link.Rel != "" && link.Rel != "alternate" {
+			continue
+		}
+		if link.Type == "" || link.Type == "text/html" {
+			return link.Href
+		}
+		if fallback == "" {
+			fallback = link.Href
+		}
+	}
+	if fallback == "" && len(links) > 0 {
+		fallback = links[0].Href
+	}
+	return fallback
+}
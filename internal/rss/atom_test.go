@@ -0,0 +1,107 @@
+package rss
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAtom(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry string
+		want  Item
+	}{
+		{
+			name: "full entry",
+			entry: `<entry>
+				<title>Release 1.0</title>
+				<id>tag:example.com,2024:1</id>
+				<link rel="self" type="application/atom+xml" href="http://example.com/1.atom"/>
+				<link rel="alternate" type="text/html" href="http://example.com/1"/>
+				<link rel="enclosure" type="application/zip" length="42" href="http://example.com/1.zip"/>
+				<published>2024-01-02T03:04:05Z</published>
+				<updated>2024-02-02T03:04:05Z</updated>
+				<summary>Short</summary>
+				<content type="html">&lt;p&gt;Long&lt;/p&gt;</content>
+				<author><name>Jane</name></author>
+				<author><name>John</name></author>
+				<category term="release" label="Releases"/>
+			</entry>`,
+			want: Item{
+				Title:       "Release 1.0",
+				Link:        "http://example.com/1",
+				Description: "Short",
+				PubDate:     "2024-01-02T03:04:05Z",
+				GUID:        "tag:example.com,2024:1",
+				Author:      "Jane, John",
+				Categories:  []string{"release"},
+				Enclosures:  []Enclosure{{URL: "http://example.com/1.zip", Type: "application/zip", Length: "42"}},
+			},
+		},
+		{
+			name: "content and updated when summary and published are missing",
+			entry: `<entry>
+				<title type="text"> Padded </title>
+				<id>urn:2</id>
+				<link href="http://example.com/2"/>
+				<updated>2024-02-02T03:04:05Z</updated>
+				<content type="html">&lt;p&gt;Body&lt;/p&gt;</content>
+			</entry>`,
+			want: Item{
+				Title:       "Padded",
+				Link:        "http://example.com/2",
+				Description: "<p>Body</p>",
+				PubDate:     "2024-02-02T03:04:05Z",
+				GUID:        "urn:2",
+			},
+		},
+		{
+			name: "xhtml content keeps its markup",
+			entry: `<entry>
+				<id>urn:3</id>
+				<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Hi</p></div></content>
+			</entry>`,
+			want: Item{
+				Description: `<div xmlns="http://www.w3.org/1999/xhtml"><p>Hi</p></div>`,
+				GUID:        "urn:3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `<?xml version="1.0" encoding="utf-8"?><feed xmlns="http://www.w3.org/2005/Atom"><title>Example</title>` + tt.entry + `</feed>`
+			feed, err := ParseFeed([]byte(data), "application/atom+xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feed.Items) != 1 {
+				t.Fatalf("got %d items, want 1", len(feed.Items))
+			}
+			if !reflect.DeepEqual(feed.Items[0], tt.want) {
+				t.Errorf("item = %+v\nwant %+v", feed.Items[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestAlternateLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		links []AtomLink
+		want  string
+	}{
+		{"no links", nil, ""},
+		{"no rel is alternate", []AtomLink{{Href: "a"}}, "a"},
+		{"skips other rels", []AtomLink{{Rel: "self", Href: "self"}, {Rel: "replies", Href: "r"}, {Rel: "alternate", Href: "a"}}, "a"},
+		{"prefers HTML", []AtomLink{{Rel: "alternate", Type: "application/json", Href: "json"}, {Rel: "alternate", Type: "text/html", Href: "html"}}, "html"},
+		{"falls back to any alternate", []AtomLink{{Rel: "alternate", Type: "application/json", Href: "json"}}, "json"},
+		{"falls back to the first link", []AtomLink{{Rel: "self", Href: "self"}, {Rel: "related", Href: "related"}}, "self"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alternateLink(tt.links); got != tt.want {
+				t.Errorf("alternateLink = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package rss
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dateLayouts are tried in order once the weekday has been stripped from a
+// date. They cover RFC822/RFC1123 and the variants publishers actually emit:
+// single-digit days, two-digit years, missing seconds, numeric or named zones,
+// and month-first dates written with or without a comma after the day.
+var dateLayouts = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -07:00",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04:05 MST",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 06 15:04 MST",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 2006 15:04:05 MST",
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006 15:04 -0700",
+	"Jan 2 2006 15:04 MST",
+	"Jan 2 2006 15:04",
+	"Jan 2 2006",
+	"January 2 2006 15:04:05 -0700",
+	"January 2 2006 15:04:05 MST",
+	"January 2 2006 15:04",
+	"January 2 2006",
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	// time.UnixDate, time.RubyDate and time.ANSIC without their weekday
+	"Jan _2 15:04:05 MST 2006",
+	"Jan 02 15:04:05 -0700 2006",
+	"Jan _2 15:04:05 2006",
+}
+
+// zoneOffsets maps the zone abbreviations seen in feeds to their UTC offset
+// in seconds. time.Parse only knows abbreviations of the local zone and
+// silently treats any other as UTC.
+var zoneOffsets = map[string]int{
+	"UT":   0,
+	"UTC":  0,
+	"GMT":  0,
+	"Z":    0,
+	"EST":  -5 * 3600,
+	"EDT":  -4 * 3600,
+	"CST":  -6 * 3600,
+	"CDT":  -5 * 3600,
+	"MST":  -7 * 3600,
+	"MDT":  -6 * 3600,
+	"PST":  -8 * 3600,
+	"PDT":  -7 * 3600,
+	"AKST": -9 * 3600,
+	"AKDT": -8 * 3600,
+	"HST":  -10 * 3600,
+	"BST":  1 * 3600,
+	"WET":  0,
+	"WEST": 1 * 3600,
+	"CET":  1 * 3600,
+	"CEST": 2 * 3600,
+	"EET":  2 * 3600,
+	"EEST": 3 * 3600,
+	"MSK":  3 * 3600,
+	"IST":  5*3600 + 1800,
+	"SGT":  8 * 3600,
+	"HKT":  8 * 3600,
+	"JST":  9 * 3600,
+	"KST":  9 * 3600,
+	"AEST": 10 * 3600,
+	"AEDT": 11 * 3600,
+	"NZST": 12 * 3600,
+	"NZDT": 13 * 3600,
+}
+
+var (
+	weekdayPrefix  = regexp.MustCompile(`(?i)^(mon|tue|wed|thu|fri|sat|sun)[a-z]*\.?(,\s*|\s+)`)
+	zoneComment    = regexp.MustCompile(`\s*\([^)]*\)$`)
+	repeatedSpaces = regexp.MustCompile(`\s+`)
+)
+
+// ParsePubDate parses a publication date in any of the formats found in
+// RSS, Atom, RDF and JSON feeds into a time.Time
+func ParsePubDate(pubDateStr string) (time.Time, error) {
+	value := normalizeDate(pubDateStr)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("empty publication date")
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return applyZoneOffset(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized publication date %q", pubDateStr)
+}
+
+// normalizeDate strips the parts of a date that vary between publishers
+// without carrying information: the weekday, comments such as "(UTC)",
+// stray commas and repeated whitespace
+func normalizeDate(value string) string {
+	value = strings.TrimSpace(value)
+	value = zoneComment.ReplaceAllString(value, "")
+	value = weekdayPrefix.ReplaceAllString(value, "")
+	value = strings.ReplaceAll(value, ",", " ")
+	value = repeatedSpaces.ReplaceAllString(value, " ")
+	value = strings.TrimSpace(value)
+
+	// Go cannot parse the RFC822 "UT" and military "Z" zones on their own
+	if strings.HasSuffix(value, " UT") || strings.HasSuffix(value, " Z") {
+		value = value[:strings.LastIndex(value, " ")] + " UTC"
+	}
+	return value
+}
+
+// applyZoneOffset fixes up times parsed with a named zone that time.Parse
+// did not recognise and therefore placed at UTC
+func applyZoneOffset(t time.Time) time.Time {
+	name, offset := t.Zone()
+	known, ok := zoneOffsets[name]
+	if !ok || known == offset {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.FixedZone(name, known))
+}
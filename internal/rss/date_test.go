@@ -0,0 +1,101 @@
+package rss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePubDate(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		// RFC 1123 and RFC 822 variants
+		{"Mon, 02 Jan 2006 15:04:05 GMT", utc(2006, 1, 2, 15, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 +0000", utc(2006, 1, 2, 15, 4, 5)},
+		{"Mon, 2 Jan 2006 15:04:05 -0500", utc(2006, 1, 2, 20, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 +01:00", utc(2006, 1, 2, 14, 4, 5)},
+		{"Mon, 02 Jan 06 15:04:05 GMT", utc(2006, 1, 2, 15, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04 GMT", utc(2006, 1, 2, 15, 4, 0)},
+		{"Mon,02 Jan 2006 15:04:05 UT", utc(2006, 1, 2, 15, 4, 5)},
+		{"Monday, 02 January 2006 15:04:05 GMT", utc(2006, 1, 2, 15, 4, 5)},
+		{"Mon., 02 Jan 2006 15:04:05 Z", utc(2006, 1, 2, 15, 4, 5)},
+		{"02 Jan 2006 15:04:05 GMT", utc(2006, 1, 2, 15, 4, 5)},
+		{"  Mon,  02 Jan 2006  15:04:05 GMT (UTC) ", utc(2006, 1, 2, 15, 4, 5)},
+
+		// Weekday without a comma
+		{"Mon 02 Jan 2006 15:04:05 GMT", utc(2006, 1, 2, 15, 4, 5)},
+		{"mon 2 Jan 2006 15:04:05 +0000", utc(2006, 1, 2, 15, 4, 5)},
+		{"Monday 2 Jan 2006 15:04", utc(2006, 1, 2, 15, 4, 0)},
+
+		// Named zones time.Parse does not know
+		{"Mon, 02 Jan 2006 15:04:05 EST", utc(2006, 1, 2, 20, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 PDT", utc(2006, 1, 2, 22, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 CEST", utc(2006, 1, 2, 13, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 IST", utc(2006, 1, 2, 9, 34, 5)},
+
+		// Month first, with and without a comma
+		{"Jan 2, 2006 15:04:05 GMT", utc(2006, 1, 2, 15, 4, 5)},
+		{"Jan 2 2006 15:04:05 -0700", utc(2006, 1, 2, 22, 4, 5)},
+		{"Jan 2 2006 15:04", utc(2006, 1, 2, 15, 4, 0)},
+		{"Jan 2 2006", utc(2006, 1, 2, 0, 0, 0)},
+		{"Monday, January 2, 2006", utc(2006, 1, 2, 0, 0, 0)},
+		{"January 2 2006 15:04", utc(2006, 1, 2, 15, 4, 0)},
+
+		// RFC 3339 and ISO 8601
+		{"2006-01-02T15:04:05Z", utc(2006, 1, 2, 15, 4, 5)},
+		{"2006-01-02T15:04:05.999+02:00", time.Date(2006, 1, 2, 13, 4, 5, 999000000, time.UTC)},
+		{"2006-01-02T15:04:05+0200", utc(2006, 1, 2, 13, 4, 5)},
+		{"2006-01-02T15:04Z", utc(2006, 1, 2, 15, 4, 0)},
+		{"2006-01-02T15:04:05", utc(2006, 1, 2, 15, 4, 5)},
+		{"2006-01-02 15:04:05", utc(2006, 1, 2, 15, 4, 5)},
+		{"2006-01-02", utc(2006, 1, 2, 0, 0, 0)},
+
+		// Unix, Ruby and ANSI C formats
+		{"Mon Jan  2 15:04:05 UTC 2006", utc(2006, 1, 2, 15, 4, 5)},
+		{"Mon Jan 02 15:04:05 -0700 2006", utc(2006, 1, 2, 22, 4, 5)},
+		{"Mon Jan  2 15:04:05 2006", utc(2006, 1, 2, 15, 4, 5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParsePubDate(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParsePubDate = %v, want %v", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePubDateRejectsGarbage(t *testing.T) {
+	for _, value := range []string{"", "   ", "yesterday", "Mon", "32 Jan 2006 15:04:05 GMT", "2006-13-01"} {
+		if got, err := ParsePubDate(value); err == nil {
+			t.Errorf("ParsePubDate(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"Mon, 02 Jan 2006 15:04:05 GMT", "02 Jan 2006 15:04:05 GMT"},
+		{"Mon 02 Jan 2006 15:04:05 GMT", "02 Jan 2006 15:04:05 GMT"},
+		{"Thursday, 5 May 2022 10:00 UT", "5 May 2022 10:00 UTC"},
+		{"Sat. 1 Mar 2025 00:00:00 Z", "1 Mar 2025 00:00:00 UTC"},
+		{"Mar 1, 2025 (GMT)", "Mar 1 2025"},
+		// Month names are not mistaken for weekdays
+		{"May 5 2022", "May 5 2022"},
+		{"Jan 2 2006 15:04", "Jan 2 2006 15:04"},
+	}
+	for _, tt := range tests {
+		if got := normalizeDate(tt.value); got != tt.want {
+			t.Errorf("normalizeDate(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package rss
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatusError is returned when a feed responds with anything other than
+// 200 OK or 304 Not Modified
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration // zero when neither the response nor the fetcher set one
+}
+
+func (e *StatusError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("fetching %s: unexpected status %s (retry after %v)", e.URL, e.Status, e.RetryAfter)
+	}
+	return fmt.Sprintf("fetching %s: unexpected status %s", e.URL, e.Status)
+}
+
+// ParseError is returned when a feed was downloaded but could not be decoded
+type ParseError struct {
+	URL string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parsing %s: %v", e.URL, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// NetworkError is returned when the request or the body transfer failed
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("fetching %s: %v", e.URL, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// Timeout reports whether the failure was caused by a timeout
+func (e *NetworkError) Timeout() bool {
+	var netErr net.Error
+	return errors.As(e.Err, &netErr) && netErr.Timeout()
+}
+
+// TooLargeError is returned when a feed body exceeds the fetcher's size cap
+type TooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("fetching %s: feed exceeds the %d byte limit", e.URL, e.Limit)
+}
+
+// DeferredError is returned without making a request while the feed's host
+// is backing us off after a 429 or 503
+type DeferredError struct {
+	URL   string
+	Host  string
+	Until time.Time
+}
+
+func (e *DeferredError) Error() string {
+	return fmt.Sprintf("fetching %s: host %s asked us to wait until %s", e.URL, e.Host, e.Until.Format(time.RFC3339))
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,300 @@
+package rss
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FetcherConfig controls how feeds are downloaded
+type FetcherConfig struct {
+	ConnectTimeout time.Duration // dialing and TLS handshake
+	ReadTimeout    time.Duration // each request, including reading the body
+	MaxBytes       int64         // largest decoded body accepted
+	UserAgent      string
+	MaxRedirects   int
+	ProxyURL       string // empty means honour HTTP_PROXY/HTTPS_PROXY
+
+	// Politeness towards each host
+	HostConcurrency int           // requests in flight per host; zero means unlimited
+	HostDelay       time.Duration // minimum gap between request starts per host
+	MaxRetryAfter   time.Duration // longest Retry-After we honour
+	RetryAfter      time.Duration // back-off after a 429 or 503 that sets no Retry-After
+}
+
+// DefaultFetcherConfig returns the settings used when nothing is configured
+func DefaultFetcherConfig() FetcherConfig {
+	return FetcherConfig{
+		ConnectTimeout: 10 * time.Second,
+		ReadTimeout:    30 * time.Second,
+		MaxBytes:       10 << 20,
+		UserAgent:      "Scrapper/1.0 (+https://github.com/kwabena369/scrapper)",
+		MaxRedirects:   5,
+
+		HostConcurrency: 2,
+		HostDelay:       time.Second,
+		MaxRetryAfter:   6 * time.Hour,
+		RetryAfter:      15 * time.Minute,
+	}
+}
+
+// Fetcher downloads and parses feeds with bounded time and size
+type Fetcher struct {
+	config FetcherConfig
+	client *http.Client
+	hosts  *hostLimiter
+}
+
+// FetchResult holds the outcome of a conditional fetch
+type FetchResult struct {
+	Items        []Item
+	Hints        PollingHints
+	StatusCode   int
+	Bytes        int64 // size of the decoded body
+	ETag         string
+	LastModified string
+	NotModified  bool
+}
+
+var defaultFetcher, _ = NewFetcher(DefaultFetcherConfig())
+
+// NewFetcher builds a Fetcher from the given configuration
+func NewFetcher(cfg FetcherConfig) (*Fetcher, error) {
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %v", cfg.ProxyURL, err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           (&net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.ReadTimeout,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		// Encodings are negotiated and decoded by hand so deflate is
+		// supported and the size cap applies to the decoded body
+		DisableCompression: true,
+	}
+
+	// Redirects are followed by Fetch, so each hop waits its turn with the
+	// host it goes to before its own ReadTimeout starts
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &Fetcher{config: cfg, client: client, hosts: newHostLimiter(cfg.HostConcurrency, cfg.HostDelay)}, nil
+}
+
+// FetchRSS fetches and parses an RSS feed from a given URL
+func FetchRSS(url string) ([]Item, error) {
+	result, err := defaultFetcher.Fetch(context.Background(), url, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
+
+// Fetch fetches and parses a feed, sending the validators from the previous
+// fetch. A 304 Not Modified response yields a result with NotModified set,
+// no items, and the validators passed in.
+func (f *Fetcher) Fetch(ctx context.Context, url, etag, lastModified string) (*FetchResult, error) {
+	resp, finish, err := f.follow(ctx, url, etag, lastModified)
+	if err != nil {
+		return nil, err
+	}
+	defer finish()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &FetchResult{StatusCode: resp.StatusCode, ETag: etag, LastModified: lastModified, NotModified: true}, nil
+	}
+
+	// Check if the request was successful
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to fetch RSS feed from %s: %s", url, resp.Status)
+		statusErr := &StatusError{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header, time.Now()),
+		}
+		if f.config.MaxRetryAfter > 0 && statusErr.RetryAfter > f.config.MaxRetryAfter {
+			statusErr.RetryAfter = f.config.MaxRetryAfter
+		}
+		// The host is overloaded or rate limiting us: hold off all its feeds.
+		// After a redirect that is the host that answered, not the feed's.
+		tooBusy := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		if tooBusy && statusErr.RetryAfter == 0 {
+			statusErr.RetryAfter = f.config.RetryAfter
+		}
+		if tooBusy && statusErr.RetryAfter > 0 {
+			f.hosts.backOff(resp.Request.URL.String(), time.Now().Add(statusErr.RetryAfter))
+		}
+		return nil, statusErr
+	}
+
+	data, err := f.readBody(url, resp)
+	if err != nil {
+		log.Printf("Failed to read RSS feed from %s: %v", url, err)
+		return nil, err
+	}
+
+	// Parse the response in whichever format the feed is published
+	feed, err := ParseFeed(data, resp.Header.Get("Content-Type"))
+	if err != nil {
+		log.Printf("Failed to parse RSS feed from %s: %v", url, err)
+		return nil, &ParseError{URL: url, Err: err}
+	}
+
+	return &FetchResult{
+		Items:        feed.Items,
+		Hints:        feed.Hints,
+		StatusCode:   resp.StatusCode,
+		Bytes:        int64(len(data)),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// follow requests url and any redirects it leads to, waiting for each host
+// the way the limiter requires. The final response is returned with its body
+// unread and a function that closes it and gives up the host, which must be
+// called once the body has been read.
+func (f *Fetcher) follow(ctx context.Context, url, etag, lastModified string) (*http.Response, func(), error) {
+	target := url
+	for redirects := 0; ; redirects++ {
+		release, err := f.hosts.acquire(ctx, target)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The deadline covers the request and reading its body, not the wait
+		reqCtx, cancel := ctx, context.CancelFunc(func() {})
+		if f.config.ReadTimeout > 0 {
+			reqCtx, cancel = context.WithTimeout(ctx, f.config.ReadTimeout)
+		}
+		finish := func() {
+			cancel()
+			release()
+		}
+
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, target, nil)
+		if err != nil {
+			finish()
+			return nil, nil, err
+		}
+		req.Header.Set("User-Agent", f.config.UserAgent)
+		req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.8")
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+
+		// Make HTTP request to fetch the feed
+		resp, err := f.client.Do(req)
+		if err != nil {
+			finish()
+			return nil, nil, &NetworkError{URL: url, Err: err}
+		}
+
+		location := resp.Header.Get("Location")
+		if !isRedirect(resp.StatusCode) || location == "" {
+			return resp, func() {
+				resp.Body.Close()
+				finish()
+			}, nil
+		}
+		resp.Body.Close()
+		finish()
+
+		if redirects >= f.config.MaxRedirects {
+			return nil, nil, &NetworkError{URL: url, Err: fmt.Errorf("stopped after %d redirects", f.config.MaxRedirects)}
+		}
+		next, err := resp.Request.URL.Parse(location)
+		if err != nil {
+			return nil, nil, &NetworkError{URL: url, Err: fmt.Errorf("bad redirect location %q: %v", location, err)}
+		}
+		target = next.String()
+	}
+}
+
+// isRedirect reports whether a status code sends the client elsewhere
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// readBody decodes the response body and enforces the size cap
+func (f *Fetcher) readBody(url string, resp *http.Response) ([]byte, error) {
+	if f.config.MaxBytes > 0 && resp.ContentLength > f.config.MaxBytes {
+		return nil, &TooLargeError{URL: url, Limit: f.config.MaxBytes}
+	}
+
+	body, err := decodeBody(resp)
+	if err != nil {
+		return nil, &ParseError{URL: url, Err: err}
+	}
+	defer body.Close()
+
+	if f.config.MaxBytes <= 0 {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, &NetworkError{URL: url, Err: err}
+		}
+		return data, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(body, f.config.MaxBytes+1))
+	if err != nil {
+		return nil, &NetworkError{URL: url, Err: err}
+	}
+	if int64(len(data)) > f.config.MaxBytes {
+		return nil, &TooLargeError{URL: url, Limit: f.config.MaxBytes}
+	}
+	return data, nil
+}
+
+// decodeBody wraps the body in a decompressor matching its Content-Encoding
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return io.NopCloser(resp.Body), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		// "deflate" should be zlib-wrapped, but some servers send raw deflate
+		buffered := bufio.NewReader(resp.Body)
+		header, err := buffered.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+		if (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
@@ -0,0 +1,332 @@
+package rss
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchFollowsRedirectsPolitely(t *testing.T) {
+	var mirrorHits atomic.Int32
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer mirror.Close()
+	origin := httptest.NewServer(http.RedirectHandler(mirror.URL+"/feed.xml", http.StatusFound))
+	defer origin.Close()
+
+	cfg := DefaultFetcherConfig()
+	cfg.HostDelay = 0
+	fetcher, err := NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err = fetcher.Fetch(ctx, origin.URL, "", "")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("first fetch: got %v, want a 429 status error", err)
+	}
+
+	// The back-off applies to the host that sent it, whichever way it is reached
+	tests := []struct {
+		name string
+		url  string
+		host string
+	}{
+		{"redirected", origin.URL, hostKey(mirror.URL)},
+		{"direct", mirror.URL + "/feed.xml", hostKey(mirror.URL)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fetcher.Fetch(ctx, tt.url, "", "")
+			var deferredErr *DeferredError
+			if !errors.As(err, &deferredErr) {
+				t.Fatalf("got %v, want a DeferredError", err)
+			}
+			if deferredErr.Host != tt.host || time.Until(deferredErr.Until) < 59*time.Minute {
+				t.Errorf("deferred by host %s until %v, want %s in an hour", deferredErr.Host, deferredErr.Until, tt.host)
+			}
+		})
+	}
+	if n := mirrorHits.Load(); n != 1 {
+		t.Errorf("mirror was asked %d times, want 1", n)
+	}
+
+	// The feed's own host was not backed off
+	release, err := fetcher.hosts.acquire(ctx, origin.URL)
+	if err != nil {
+		t.Fatalf("origin host deferred: %v", err)
+	}
+	release()
+}
+
+func TestFetchRedirectsReleaseHostSlots(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/new", http.StatusMovedPermanently))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<rss version="2.0"><channel><item><title>Moved</title></item></channel></rss>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// With one slot per host, a same-host redirect must give up the slot
+	// of the first request before taking one for the next
+	cfg := DefaultFetcherConfig()
+	cfg.HostConcurrency = 1
+	cfg.HostDelay = 0
+	fetcher, err := NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		result, err := fetcher.Fetch(ctx, server.URL+"/old", "", "")
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+		if len(result.Items) != 1 || result.Items[0].Title != "Moved" {
+			t.Fatalf("fetch %d: got %+v", i, result.Items)
+		}
+	}
+}
+
+func TestFetchBacksOffWithoutRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := DefaultFetcherConfig()
+	cfg.HostDelay = 0
+	cfg.RetryAfter = 10 * time.Minute
+	fetcher, err := NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err = fetcher.Fetch(ctx, server.URL, "", "")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.RetryAfter != cfg.RetryAfter {
+		t.Fatalf("got %v, want a 503 status error retrying after %v", err, cfg.RetryAfter)
+	}
+	_, err = fetcher.Fetch(ctx, server.URL, "", "")
+	var deferredErr *DeferredError
+	if !errors.As(err, &deferredErr) || time.Until(deferredErr.Until) < 9*time.Minute {
+		t.Fatalf("got %v, want a DeferredError for ten minutes", err)
+	}
+}
+
+func TestFetchHostDelayIsNotReadTime(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/new", http.StatusMovedPermanently))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel><item><title>Moved</title></item></channel></rss>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The redirect waits out the host delay, which is longer than a request
+	// may take; only the requests themselves are timed
+	cfg := DefaultFetcherConfig()
+	cfg.HostDelay = 300 * time.Millisecond
+	cfg.ReadTimeout = 200 * time.Millisecond
+	fetcher, err := NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	result, err := fetcher.Fetch(context.Background(), server.URL+"/old", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 1 {
+		t.Fatalf("got %+v", result.Items)
+	}
+	if elapsed := time.Since(start); elapsed < cfg.HostDelay {
+		t.Errorf("redirect followed after %v, before the host delay of %v", elapsed, cfg.HostDelay)
+	}
+}
+
+func TestFetchStopsAfterMaxRedirects(t *testing.T) {
+	server := httptest.NewServer(http.RedirectHandler("/loop", http.StatusFound))
+	defer server.Close()
+
+	cfg := DefaultFetcherConfig()
+	cfg.HostDelay = 0
+	cfg.MaxRedirects = 2
+	fetcher, err := NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fetcher.Fetch(context.Background(), server.URL, "", "")
+	var networkErr *NetworkError
+	if !errors.As(err, &networkErr) {
+		t.Fatalf("got %v, want a NetworkError", err)
+	}
+}
+
+const testFeed = `<rss version="2.0"><channel><item><title>Compressed</title></item></channel></rss>`
+
+func TestFetchDecodesCompressedBodies(t *testing.T) {
+	compress := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		io.WriteString(w, testFeed)
+		w.Close()
+		return buf.Bytes()
+	}
+	tests := []struct {
+		encoding string
+		body     []byte
+	}{
+		{"", []byte(testFeed)},
+		{"gzip", compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })},
+		{"deflate", compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })},
+		{"deflate", compress(func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		})},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", i, tt.encoding), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					t.Errorf("Accept-Encoding %q does not offer gzip", r.Header.Get("Accept-Encoding"))
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			result, err := newTestFetcher(t, DefaultFetcherConfig()).Fetch(context.Background(), server.URL, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Items) != 1 || result.Items[0].Title != "Compressed" || result.Bytes != int64(len(testFeed)) {
+				t.Fatalf("got %d bytes and %+v", result.Bytes, result.Items)
+			}
+		})
+	}
+}
+
+func TestFetchEnforcesSizeCap(t *testing.T) {
+	var big bytes.Buffer
+	gz := gzip.NewWriter(&big)
+	gz.Write(bytes.Repeat([]byte(" "), 4096))
+	gz.Close()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"declared length", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "4096")
+			w.Write(bytes.Repeat([]byte(" "), 4096))
+		}},
+		{"streamed", func(w http.ResponseWriter, r *http.Request) {
+			w.Write(bytes.Repeat([]byte(" "), 2048))
+			w.(http.Flusher).Flush()
+			w.Write(bytes.Repeat([]byte(" "), 2048))
+		}},
+		// The cap applies to the decoded body, so compression cannot hide a large feed
+		{"decoded", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(big.Bytes())
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			cfg := DefaultFetcherConfig()
+			cfg.MaxBytes = 1024
+			_, err := newTestFetcher(t, cfg).Fetch(context.Background(), server.URL, "", "")
+			var tooLargeErr *TooLargeError
+			if !errors.As(err, &tooLargeErr) || tooLargeErr.Limit != 1024 {
+				t.Fatalf("got %v, want a TooLargeError", err)
+			}
+		})
+	}
+}
+
+// newTestFetcher builds a fetcher from cfg without the host delay
+func newTestFetcher(t *testing.T, cfg FetcherConfig) *Fetcher {
+	t.Helper()
+	cfg.HostDelay = 0
+	fetcher, err := NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fetcher
+}
+
+func TestFetchErrorTypes(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/garbage", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a feed"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := DefaultFetcherConfig()
+	cfg.ReadTimeout = 100 * time.Millisecond
+	fetcher := newTestFetcher(t, cfg)
+	ctx := context.Background()
+
+	_, err := fetcher.Fetch(ctx, server.URL+"/missing", "", "")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || statusErr.RetryAfter != 0 {
+		t.Errorf("missing feed: got %v, want a 404 StatusError", err)
+	}
+
+	_, err = fetcher.Fetch(ctx, server.URL+"/garbage", "", "")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("garbage feed: got %v, want a ParseError", err)
+	}
+
+	_, err = fetcher.Fetch(ctx, server.URL+"/slow", "", "")
+	var networkErr *NetworkError
+	if !errors.As(err, &networkErr) || !networkErr.Timeout() {
+		t.Errorf("slow feed: got %v, want a NetworkError that timed out", err)
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, err = fetcher.Fetch(ctx, closed.URL, "", "")
+	if !errors.As(err, &networkErr) || networkErr.Timeout() {
+		t.Errorf("unreachable feed: got %v, want a NetworkError that did not time out", err)
+	}
+}
@@ -0,0 +1,83 @@
+package rss
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PollingHints are a publisher's own suggestions for how often its feed
+// should be polled
+type PollingHints struct {
+	TTL            time.Duration  // RSS <ttl>
+	UpdateInterval time.Duration  // sy:updatePeriod divided by sy:updateFrequency
+	SkipHours      []int          // UTC hours during which the feed should not be polled
+	SkipDays       []time.Weekday // days on which the feed should not be polled
+}
+
+// SyndicationHints holds the channel-level elements that carry polling hints.
+// It is embedded in the RSS, RDF and Atom feed types.
+type SyndicationHints struct {
+	TTL             string   `xml:"ttl"`
+	UpdatePeriod    string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	SkipHours       []string `xml:"skipHours>hour"`
+	SkipDays        []string `xml:"skipDays>day"`
+}
+
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// pollingHints converts the raw elements into PollingHints, ignoring any
+// value that is malformed
+func (h SyndicationHints) pollingHints() PollingHints {
+	var hints PollingHints
+
+	if minutes, err := strconv.Atoi(strings.TrimSpace(h.TTL)); err == nil && minutes > 0 {
+		hints.TTL = time.Duration(minutes) * time.Minute
+	}
+
+	// The syndication module defaults to once a day when only one of the
+	// two elements is present
+	period := strings.ToLower(strings.TrimSpace(h.UpdatePeriod))
+	frequency := strings.TrimSpace(h.UpdateFrequency)
+	if period != "" || frequency != "" {
+		length, ok := updatePeriods[period]
+		if !ok {
+			length = updatePeriods["daily"]
+		}
+		times, err := strconv.Atoi(frequency)
+		if err != nil || times < 1 {
+			times = 1
+		}
+		hints.UpdateInterval = length / time.Duration(times)
+	}
+
+	for _, value := range h.SkipHours {
+		// RSS counts hours 0-23, though some feeds use 24 for midnight
+		if hour, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && hour >= 0 && hour <= 24 {
+			hints.SkipHours = append(hints.SkipHours, hour%24)
+		}
+	}
+	for _, value := range h.SkipDays {
+		if day, ok := weekdays[strings.ToLower(strings.TrimSpace(value))]; ok {
+			hints.SkipDays = append(hints.SkipDays, day)
+		}
+	}
+	return hints
+}
@@ -0,0 +1,121 @@
+package rss
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters that identify a campaign or click
+// rather than the content, and are dropped when normalizing links
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+	"igshid":  true,
+	"ref_src": true,
+}
+
+// IdentityKey returns a stable key identifying an item across fetches: its
+// GUID when the feed provides one, otherwise its normalized link, otherwise
+// a hash of its title and description
+func IdentityKey(guid, link, title, description string) string {
+	if guid = strings.TrimSpace(guid); guid != "" {
+		return "guid:" + guid
+	}
+	if link = NormalizeLink(link); link != "" {
+		return "link:" + link
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(title) + "\n" + strings.TrimSpace(description)))
+	return "hash:" + hex.EncodeToString(sum[:])
+}
+
+// IdentityKey returns the item's identity key
+func (i Item) IdentityKey() string {
+	return IdentityKey(i.GUID, i.Link, i.Title, i.Description)
+}
+
+// ContentHash returns a hash of the parts of an item a publisher may edit
+// after publishing, so a changed hash means the stored copy is out of date.
+// Categories are compared as a set, and the link is normalized as for
+// IdentityKey so rotating tracking parameters is not an edit.
+func ContentHash(title, link, description, author string, categories, enclosureURLs []string) string {
+	sorted := append([]string(nil), categories...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, part := range []string{title, NormalizeLink(link), description, author, strings.Join(sorted, "\x1f"), strings.Join(enclosureURLs, "\x1f")} {
+		h.Write([]byte(strings.TrimSpace(part)))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ContentHash returns the hash of the item's editable content
+func (i Item) ContentHash() string {
+	var enclosureURLs []string
+	for _, enclosure := range i.Enclosures {
+		enclosureURLs = append(enclosureURLs, enclosure.URL)
+	}
+	return ContentHash(i.Title, i.Link, i.Description, i.Author, i.Categories, enclosureURLs)
+}
+
+// NormalizeLink canonicalizes a link so that trivially different forms of the
+// same URL compare equal: the scheme and host are lowercased, the scheme's
+// default port and tracking parameters are dropped, and the remaining query
+// parameters are sorted. Fragments are kept, since feeds such as changelogs
+// link every item to its own anchor on one page.
+func NormalizeLink(link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return link
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Host)
+	switch parsed.Scheme {
+	case "http":
+		host = strings.TrimSuffix(host, ":80")
+	case "https":
+		host = strings.TrimSuffix(host, ":443")
+	}
+	parsed.Host = host
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	parsed.RawQuery = strings.Join(parts, "&")
+	parsed.ForceQuery = false
+
+	return parsed.String()
+}
@@ -0,0 +1,47 @@
+package rss
+
+import "testing"
+
+func TestNormalizeLink(t *testing.T) {
+	tests := []struct {
+		link, want string
+	}{
+		{"", ""},
+		{"  HTTP://Example.COM  ", "http://example.com/"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"http://example.com:443/a", "http://example.com:443/a"},
+		{"http://example.com/a?utm_source=rss&b=2&a=1&fbclid=x", "http://example.com/a?a=1&b=2"},
+		{"http://example.com/a?utm_medium=email", "http://example.com/a"},
+		{"http://example.com/changelog#v2", "http://example.com/changelog#v2"},
+		{"http://example.com/changelog?utm_source=rss#v2", "http://example.com/changelog#v2"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeLink(tt.link); got != tt.want {
+			t.Errorf("NormalizeLink(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestIdentityKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		a, b         Item
+		sameIdentity bool
+	}{
+		{"same GUID", Item{GUID: "1", Title: "A"}, Item{GUID: "1", Title: "B"}, true},
+		{"GUID wins over link", Item{GUID: "1", Link: "http://example.com/a"}, Item{GUID: "2", Link: "http://example.com/a"}, false},
+		{"tracking parameters", Item{Link: "http://example.com/a"}, Item{Link: "http://example.com/a?utm_source=rss"}, true},
+		{"anchors of one page", Item{Link: "http://example.com/changelog#v1"}, Item{Link: "http://example.com/changelog#v2"}, false},
+		{"content without a link", Item{Title: "A", Description: "x"}, Item{Title: "A", Description: "x"}, true},
+		{"different content without a link", Item{Title: "A"}, Item{Title: "B"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.a.IdentityKey() == tt.b.IdentityKey(); same != tt.sameIdentity {
+				t.Errorf("keys %q and %q: same = %v, want %v", tt.a.IdentityKey(), tt.b.IdentityKey(), same, tt.sameIdentity)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// JSONFeed represents the structure of a JSON Feed 1.0/1.1 document
+type JSONFeed struct {
+	Version string         `json:"version"`
+	Title   string         `json:"title"`
+	HomeURL string         `json:"home_page_url"`
+	FeedURL string         `json:"feed_url"`
+	Items   []JSONFeedItem `json:"items"`
+}
+
+// JSONFeedItem represents a single entry in a JSON Feed
+type JSONFeedItem struct {
+	ID            jsonFeedID           `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Author        *JSONFeedAuthor      `json:"author"`
+	Authors       []JSONFeedAuthor     `json:"authors"`
+	Tags          []string             `json:"tags"`
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+// JSONFeedAuthor is an entry of a JSON Feed "authors" array
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// JSONFeedAttachment is an entry of a JSON Feed "attachments" array
+type JSONFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	Title       string `json:"title"`
+	SizeInBytes int64  `json:"size_in_bytes"`
+}
+
+// jsonFeedID accepts both string and numeric item ids; the spec asks for a
+// string but plenty of publishers emit numbers.
+type jsonFeedID string
+
+func (id *jsonFeedID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = jsonFeedID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = jsonFeedID(n.String())
+	return nil
+}
+
+// isJSONFeed reports whether a document should be parsed as JSON Feed: it
+// is served as application/feed+json, or it is a JSON object whose version
+// names a JSON Feed version. Other JSON, such as an API error served as
+// application/json, is not.
+func isJSONFeed(data []byte, contentType string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == "application/feed+json" {
+		return true
+	}
+	if !isJSON(data) {
+		return false
+	}
+	var header struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\ufeff")), &header); err != nil {
+		return false
+	}
+	return strings.Contains(header.Version, "jsonfeed.org/version/")
+}
+
+// isJSON reports whether a document looks like a JSON object
+func isJSON(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// parseJSONFeed decodes a JSON Feed document and maps its items
+func parseJSONFeed(data []byte) (*Feed, error) {
+	var feed JSONFeed
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\ufeff")), &feed); err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(feed.Items))
+	for _, entry := range feed.Items {
+		items = append(items, entry.toItem())
+	}
+	return &Feed{Items: items}, nil
+}
+
+// toItem maps a JSON Feed item onto the common Item type
+func (e JSONFeedItem) toItem() Item {
+	link := e.URL
+	if link == "" {
+		link = e.ExternalURL
+	}
+
+	description := e.ContentHTML
+	if description == "" {
+		description = e.ContentText
+	}
+	if description == "" {
+		description = e.Summary
+	}
+
+	pubDate := e.DatePublished
+	if pubDate == "" {
+		pubDate = e.DateModified
+	}
+
+	// JSON Feed 1.1 replaced the single "author" object with "authors"
+	authors := e.Authors
+	if len(authors) == 0 && e.Author != nil {
+		authors = []JSONFeedAuthor{*e.Author}
+	}
+	var names []string
+	for _, author := range authors {
+		if author.Name != "" {
+			names = append(names, author.Name)
+		}
+	}
+
+	var enclosures []Enclosure
+	for _, attachment := range e.Attachments {
+		enclosure := Enclosure{URL: attachment.URL, Type: attachment.MimeType}
+		if attachment.SizeInBytes > 0 {
+			enclosure.Length = strconv.FormatInt(attachment.SizeInBytes, 10)
+		}
+		enclosures = append(enclosures, enclosure)
+	}
+
+	return Item{
+		Title:       e.Title,
+		Link:        link,
+		Description: description,
+		PubDate:     pubDate,
+		GUID:        string(e.ID),
+		Author:      strings.Join(names, ", "),
+		Categories:  e.Tags,
+		Enclosures:  enclosures,
+	}
+}
@@ -0,0 +1,114 @@
+package rss
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJSONFeed(t *testing.T) {
+	tests := []struct {
+		name string
+		item string
+		want Item
+	}{
+		{
+			name: "full item",
+			item: `{
+				"id": "https://example.com/1",
+				"url": "https://example.com/1",
+				"title": "First",
+				"content_html": "<p>HTML</p>",
+				"content_text": "Text",
+				"summary": "Summary",
+				"date_published": "2024-01-02T03:04:05Z",
+				"date_modified": "2024-02-02T03:04:05Z",
+				"authors": [{"name": "Jane"}, {"url": "https://example.com/anon"}, {"name": "John"}],
+				"tags": ["go", "feeds"],
+				"attachments": [{"url": "https://example.com/1.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 1234}]
+			}`,
+			want: Item{
+				Title:       "First",
+				Link:        "https://example.com/1",
+				Description: "<p>HTML</p>",
+				PubDate:     "2024-01-02T03:04:05Z",
+				GUID:        "https://example.com/1",
+				Author:      "Jane, John",
+				Categories:  []string{"go", "feeds"},
+				Enclosures:  []Enclosure{{URL: "https://example.com/1.mp3", Type: "audio/mpeg", Length: "1234"}},
+			},
+		},
+		{
+			name: "fallbacks",
+			item: `{
+				"id": 42,
+				"external_url": "https://elsewhere.example/2",
+				"content_text": "Text",
+				"date_modified": "2024-02-02T03:04:05Z",
+				"author": {"name": "Jane"},
+				"attachments": [{"url": "https://example.com/2.png", "mime_type": "image/png"}]
+			}`,
+			want: Item{
+				Link:        "https://elsewhere.example/2",
+				Description: "Text",
+				PubDate:     "2024-02-02T03:04:05Z",
+				GUID:        "42",
+				Author:      "Jane",
+				Enclosures:  []Enclosure{{URL: "https://example.com/2.png", Type: "image/png"}},
+			},
+		},
+		{
+			name: "summary only",
+			item: `{"id": "3", "summary": "Summary", "authors": [], "author": {"name": "Legacy"}}`,
+			want: Item{Description: "Summary", GUID: "3", Author: "Legacy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"version": "https://jsonfeed.org/version/1.1", "title": "Example", "items": [` + tt.item + `]}`
+			feed, err := ParseFeed([]byte(data), "application/feed+json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feed.Items) != 1 {
+				t.Fatalf("got %d items, want 1", len(feed.Items))
+			}
+			if !reflect.DeepEqual(feed.Items[0], tt.want) {
+				t.Errorf("item = %+v\nwant %+v", feed.Items[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONFeedRejectsInvalidIDs(t *testing.T) {
+	data := `{"items": [{"id": {"nested": true}}]}`
+	if _, err := ParseFeed([]byte(data), "application/feed+json"); err == nil {
+		t.Fatal("ParseFeed accepted an object as an item id")
+	}
+}
+
+func TestIsJSONFeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        string
+		want        bool
+	}{
+		{"feed+json", "application/feed+json", `[]`, true},
+		{"json with a version", "application/json; charset=utf-8", `{"version": "https://jsonfeed.org/version/1.1"}`, true},
+		{"json without a version", "application/json", `{"error": "not found"}`, false},
+		{"json with another version", "application/json", `{"version": "2.0"}`, false},
+		{"sniffed object", "text/plain", " \r\n{\"version\": \"https://jsonfeed.org/version/1\"}", true},
+		{"sniffed after BOM", "", "\ufeff{\"version\": \"https://jsonfeed.org/version/1.1\"}", true},
+		{"invalid json", "application/json", `{"version": `, false},
+		{"XML", "application/xml", `<rss/>`, false},
+		{"XML served as text", "text/plain", `<rss/>`, false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isJSONFeed([]byte(tt.data), tt.contentType); got != tt.want {
+				t.Errorf("isJSONFeed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
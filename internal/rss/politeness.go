@@ -0,0 +1,112 @@
+package rss
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostLimiter keeps the fetcher polite towards each host: it caps how many
+// requests are in flight, spaces out their start times, and holds off
+// entirely while a host has asked us to back off with Retry-After
+type hostLimiter struct {
+	concurrency int
+	delay       time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	slots        chan struct{}
+	nextStart    time.Time
+	blockedUntil time.Time
+}
+
+func newHostLimiter(concurrency int, delay time.Duration) *hostLimiter {
+	return &hostLimiter{concurrency: concurrency, delay: delay, hosts: make(map[string]*hostState)}
+}
+
+// state returns the bookkeeping for a host, creating it on first use.
+// Callers must hold l.mu.
+func (l *hostLimiter) state(host string) *hostState {
+	state, ok := l.hosts[host]
+	if !ok {
+		state = &hostState{}
+		if l.concurrency > 0 {
+			state.slots = make(chan struct{}, l.concurrency)
+		}
+		l.hosts[host] = state
+	}
+	return state
+}
+
+// acquire waits until a request to host may start and returns a function
+// that must be called once it has finished. It fails immediately with a
+// DeferredError while the host is backing us off.
+func (l *hostLimiter) acquire(ctx context.Context, rawURL string) (func(), error) {
+	host := hostKey(rawURL)
+
+	l.mu.Lock()
+	state := l.state(host)
+	blockedUntil := state.blockedUntil
+	l.mu.Unlock()
+
+	if time.Now().Before(blockedUntil) {
+		return nil, &DeferredError{URL: rawURL, Host: host, Until: blockedUntil}
+	}
+
+	release := func() {}
+	if state.slots != nil {
+		select {
+		case state.slots <- struct{}{}:
+			release = func() { <-state.slots }
+		case <-ctx.Done():
+			return nil, &NetworkError{URL: rawURL, Err: ctx.Err()}
+		}
+	}
+
+	// Reserve the next start time so concurrent requests stay spaced out
+	l.mu.Lock()
+	start := time.Now()
+	if state.nextStart.After(start) {
+		start = state.nextStart
+	}
+	state.nextStart = start.Add(l.delay)
+	l.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, &NetworkError{URL: rawURL, Err: ctx.Err()}
+		}
+	}
+	return release, nil
+}
+
+// backOff stops requests to the host of rawURL until the given time
+func (l *hostLimiter) backOff(rawURL string, until time.Time) {
+	host := hostKey(rawURL)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state := l.state(host)
+	if until.After(state.blockedUntil) {
+		state.blockedUntil = until
+	}
+}
+
+// hostKey identifies the host a URL points at
+func hostKey(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(parsed.Host)
+}
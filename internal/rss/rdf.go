@@ -0,0 +1,67 @@
+package rss
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+const rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// RDF represents the structure of an RSS 1.0 feed. Unlike RSS 2.0, items
+// are siblings of the channel rather than children of it.
+type RDF struct {
+	XMLName xml.Name   `xml:"RDF"`
+	Channel RDFChannel `xml:"channel"`
+	Items   []RDFItem  `xml:"item"`
+}
+
+// RDFChannel holds the RSS 1.0 feed metadata
+type RDFChannel struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	SyndicationHints
+}
+
+// RDFItem represents a single entry in an RSS 1.0 feed
+type RDFItem struct {
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+}
+
+// parseRDF decodes an RSS 1.0 document and maps its items
+func parseRDF(data []byte) (*Feed, error) {
+	var feed RDF
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(feed.Items))
+	for _, entry := range feed.Items {
+		items = append(items, entry.toItem())
+	}
+	return &Feed{Items: items, Hints: feed.Channel.SyndicationHints.pollingHints()}, nil
+}
+
+// toItem maps an RSS 1.0 item onto the common Item type
+func (e RDFItem) toItem() Item {
+	link := strings.TrimSpace(e.Link)
+	if link == "" {
+		link = e.About
+	}
+
+	return Item{
+		Title:       strings.TrimSpace(e.Title),
+		Link:        link,
+		Description: strings.TrimSpace(e.Description),
+		PubDate:     strings.TrimSpace(e.Date),
+		GUID:        e.About,
+		Author:      strings.TrimSpace(e.Creator),
+		Categories:  e.Subjects,
+	}
+}
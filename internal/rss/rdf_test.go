@@ -0,0 +1,70 @@
+package rss
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRDF(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF
+	xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:sy="http://purl.org/rss/1.0/modules/syndication/"
+	xmlns="http://purl.org/rss/1.0/">
+	<channel rdf:about="http://example.org/">
+		<title>Example</title>
+		<link>http://example.org/</link>
+		<sy:updatePeriod>daily</sy:updatePeriod>
+		<sy:updateFrequency>2</sy:updateFrequency>
+	</channel>
+	<item rdf:about="http://example.org/report-1">
+		<title> Annual report </title>
+		<link>http://example.org/report-1.html</link>
+		<description>The report</description>
+		<dc:date>2004-05-06T07:08:09+02:00</dc:date>
+		<dc:creator>Statistics Office</dc:creator>
+		<dc:subject>economy</dc:subject>
+		<dc:subject>statistics</dc:subject>
+	</item>
+	<item rdf:about="http://example.org/report-2">
+		<title>No link</title>
+	</item>
+</rdf:RDF>`
+
+	feed, err := ParseFeed([]byte(data), "application/rdf+xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Item{
+		{
+			Title:       "Annual report",
+			Link:        "http://example.org/report-1.html",
+			Description: "The report",
+			PubDate:     "2004-05-06T07:08:09+02:00",
+			GUID:        "http://example.org/report-1",
+			Author:      "Statistics Office",
+			Categories:  []string{"economy", "statistics"},
+		},
+		{
+			Title: "No link",
+			Link:  "http://example.org/report-2",
+			GUID:  "http://example.org/report-2",
+		},
+	}
+	if !reflect.DeepEqual(feed.Items, want) {
+		t.Errorf("items = %+v\nwant %+v", feed.Items, want)
+	}
+	if feed.Hints.UpdateInterval != 12*time.Hour {
+		t.Errorf("update interval = %v, want 12h", feed.Hints.UpdateInterval)
+	}
+
+	date, err := ParsePubDate(feed.Items[0].PubDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2004, 5, 6, 5, 8, 9, 0, time.UTC); !date.Equal(want) {
+		t.Errorf("dc:date parsed as %v, want %v", date, want)
+	}
+}
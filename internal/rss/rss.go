@@ -1,10 +1,10 @@
 package rss
 
 import (
+	"bytes"
 	"encoding/xml"
-	"log"
-	"net/http"
-	"time"
+	"errors"
+	"fmt"
 )
 
 // RSS represents the structure of an RSS feed
@@ -18,46 +18,86 @@ type Channel struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	Items       []Item `xml:"item"`
+	SyndicationHints
 }
 
 // Item represents a single entry in the RSS feed
 type Item struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Title       string      `xml:"title"`
+	Link        string      `xml:"link"`
+	Description string      `xml:"description"`
+	PubDate     string      `xml:"pubDate"`
+	GUID        string      `xml:"guid"`
+	Author      string      `xml:"author"`
+	Categories  []string    `xml:"category"`
+	Enclosures  []Enclosure `xml:"enclosure"`
+}
+
+// Enclosure is a media file attached to an item
+type Enclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// Feed is a parsed feed document
+type Feed struct {
+	Items []Item
+	Hints PollingHints
 }
 
-// FetchRSS fetches and parses an RSS feed from a given URL
-func FetchRSS(url string) ([]Item, error) {
-	// Make HTTP request to fetch the feed
-	resp, err := http.Get(url)
+// Parse detects the format of a feed document and returns its items. The
+// Content-Type is consulted first; otherwise the body itself is sniffed.
+func Parse(data []byte, contentType string) ([]Item, error) {
+	feed, err := ParseFeed(data, contentType)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return feed.Items, nil
+}
 
-	// Check if the request was successful
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Failed to fetch RSS feed from %s: %s", url, resp.Status)
-		return nil, err
+// ParseFeed is like Parse but also returns the feed's polling hints
+func ParseFeed(data []byte, contentType string) (*Feed, error) {
+	if isJSONFeed(data, contentType) {
+		return parseJSONFeed(data)
+	}
+	if isJSON(data) {
+		return nil, errors.New("unsupported feed format: JSON without a JSON Feed version")
 	}
 
-	// Parse the XML response
-	var rss RSS
-	decoder := xml.NewDecoder(resp.Body)
-	err = decoder.Decode(&rss)
+	root, err := rootElement(data)
 	if err != nil {
-		log.Printf("Failed to parse RSS feed from %s: %v", url, err)
 		return nil, err
 	}
 
-	return rss.Channel.Items, nil
+	switch root.Local {
+	case "rss":
+		var rss RSS
+		if err := xml.Unmarshal(data, &rss); err != nil {
+			return nil, err
+		}
+		return &Feed{Items: rss.Channel.Items, Hints: rss.Channel.SyndicationHints.pollingHints()}, nil
+	case "feed":
+		return parseAtom(data)
+	case "RDF":
+		if root.Space != rdfNamespace {
+			break
+		}
+		return parseRDF(data)
+	}
+	return nil, fmt.Errorf("unsupported feed format <%s>", root.Local)
 }
 
-// ParsePubDate parses the RSS pubDate string into a time.Time
-func ParsePubDate(pubDateStr string) (time.Time, error) {
-	// Use the correct layout for RFC1123 with GMT
-	const layout = "Mon, 02 Jan 2006 15:04:05 MST"
-	return time.Parse(layout, pubDateStr)
-}
\ No newline at end of file
+// rootElement returns the name of the first element in an XML document
+func rootElement(data []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package rss
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDetectsFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        string
+		wantTitle   string
+		wantErr     bool
+	}{
+		{
+			name:      "RSS 2.0",
+			data:      `<?xml version="1.0"?><rss version="2.0"><channel><item><title>RSS</title></item></channel></rss>`,
+			wantTitle: "RSS",
+		},
+		{
+			name:      "Atom",
+			data:      `<feed xmlns="http://www.w3.org/2005/Atom"><entry><title>Atom</title></entry></feed>`,
+			wantTitle: "Atom",
+		},
+		{
+			name:      "RSS 1.0",
+			data:      `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/"><item><title>RDF</title></item></rdf:RDF>`,
+			wantTitle: "RDF",
+		},
+		{
+			name:        "JSON Feed by content type",
+			contentType: "application/feed+json; charset=utf-8",
+			data:        ` {"version": "https://jsonfeed.org/version/1.1", "items": [{"id": "1", "title": "JSON"}]}`,
+			wantTitle:   "JSON",
+		},
+		{
+			name:      "JSON Feed sniffed",
+			data:      "\ufeff{\"version\": \"https://jsonfeed.org/version/1\", \"items\": [{\"id\": \"1\", \"title\": \"JSON\"}]}",
+			wantTitle: "JSON",
+		},
+		{
+			name:        "JSON that is not a feed",
+			contentType: "application/json",
+			data:        `{"error": "not found", "items": [{"title": "JSON"}]}`,
+			wantErr:     true,
+		},
+		{
+			name:    "RDF outside the RDF namespace",
+			data:    `<RDF><item><title>RDF</title></item></RDF>`,
+			wantErr: true,
+		},
+		{
+			name:    "HTML page",
+			data:    `<html><body>Not a feed</body></html>`,
+			wantErr: true,
+		},
+		{
+			name:    "empty body",
+			data:    ``,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := Parse([]byte(tt.data), tt.contentType)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse succeeded with %d items, want an error", len(items))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 1 || items[0].Title != tt.wantTitle {
+				t.Fatalf("Parse = %+v, want one item titled %q", items, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestParseRSS(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+	<title>Example</title>
+	<link>http://example.com/</link>
+	<ttl>60</ttl>
+	<item>
+		<title>First post</title>
+		<link>http://example.com/first</link>
+		<description>&lt;p&gt;Hello&lt;/p&gt;</description>
+		<pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate>
+		<guid isPermaLink="false">first-post</guid>
+		<author>jane@example.com (Jane)</author>
+		<category>go</category>
+		<category>feeds</category>
+		<enclosure url="http://example.com/first.mp3" type="audio/mpeg" length="1234"/>
+	</item>
+	<item>
+		<title>Second post</title>
+	</item>
+</channel>
+</rss>`
+
+	feed, err := ParseFeed([]byte(data), "application/rss+xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Item{
+		{
+			Title:       "First post",
+			Link:        "http://example.com/first",
+			Description: "<p>Hello</p>",
+			PubDate:     "Mon, 02 Jan 2006 15:04:05 GMT",
+			GUID:        "first-post",
+			Author:      "jane@example.com (Jane)",
+			Categories:  []string{"go", "feeds"},
+			Enclosures:  []Enclosure{{URL: "http://example.com/first.mp3", Type: "audio/mpeg", Length: "1234"}},
+		},
+		{Title: "Second post"},
+	}
+	if !reflect.DeepEqual(feed.Items, want) {
+		t.Errorf("items = %+v\nwant %+v", feed.Items, want)
+	}
+	if feed.Hints.TTL.Minutes() != 60 {
+		t.Errorf("TTL = %v, want 1h", feed.Hints.TTL)
+	}
+}
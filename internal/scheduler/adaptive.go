@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/handlers"
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/rss"
+)
+
+// nextPoll decides how long to wait before fetching a feed again and when
+// that will be. Feeds with an explicit poll_interval keep it; the others
+// adapt to how often they publish, which only a successful fetch tells us,
+// so after a failed one they keep their current interval.
+func nextPoll(feed models.Feed, newItems int, fetched bool, now time.Time) (time.Duration, time.Time) {
+	interval := handlers.PollIntervalFor(feed)
+	if feed.PollInterval == 0 {
+		switch {
+		case fetched:
+			interval = adaptInterval(feed, newItems)
+		case feed.CurrentInterval > 0:
+			interval = time.Duration(feed.CurrentInterval) * time.Second
+		}
+	}
+	return interval, skipQuietPeriods(now.Add(interval), feed.Hints)
+}
+
+// adaptInterval halves the interval after a fetch that found new items and
+// doubles it after one that didn't, never polling more often than the
+// publisher's own <ttl> or sy:updatePeriod allow
+func adaptInterval(feed models.Feed, newItems int) time.Duration {
+	interval := time.Duration(feed.CurrentInterval) * time.Second
+	if interval == 0 {
+		interval = handlers.DefaultPollInterval
+	}
+
+	if newItems > 0 {
+		interval /= 2
+	} else {
+		interval *= 2
+	}
+
+	if feed.Hints != nil {
+		if floor := time.Duration(feed.Hints.MinInterval) * time.Second; interval < floor {
+			interval = floor
+		}
+	}
+
+	if interval < handlers.MinPollInterval {
+		interval = handlers.MinPollInterval
+	}
+	if interval > handlers.MaxPollInterval {
+		interval = handlers.MaxPollInterval
+	}
+	return interval
+}
+
+// skipQuietPeriods moves a poll time past the hours and days the publisher
+// asked not to be polled in <skipHours> and <skipDays>, which are in UTC
+func skipQuietPeriods(next time.Time, hints *models.PollingHints) time.Time {
+	if hints == nil || (len(hints.SkipHours) == 0 && len(hints.SkipDays) == 0) {
+		return next
+	}
+
+	skipHours := make(map[int]bool)
+	for _, hour := range hints.SkipHours {
+		skipHours[hour] = true
+	}
+	skipDays := make(map[string]bool)
+	for _, day := range hints.SkipDays {
+		skipDays[day] = true
+	}
+
+	// A week of hours is enough to find an open slot if there is one
+	candidate := next.UTC()
+	for i := 0; i < 7*24; i++ {
+		switch {
+		case skipDays[candidate.Weekday().String()]:
+			candidate = candidate.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		case skipHours[candidate.Hour()]:
+			candidate = candidate.Truncate(time.Hour).Add(time.Hour)
+		default:
+			return candidate
+		}
+	}
+	return next
+}
+
+// retryAfter reports when a host that rate limited or deferred a scrape is
+// willing to be asked again
+func retryAfter(err error) (time.Time, bool) {
+	var deferredErr *rss.DeferredError
+	if errors.As(err, &deferredErr) {
+		return deferredErr.Until, true
+	}
+	var statusErr *rss.StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return time.Now().Add(statusErr.RetryAfter), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/handlers"
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/rss"
+	"github.com/kwabena369/scrapper/internal/store"
+)
+
+// maxSleep bounds how long the scheduler sleeps between checks, so feeds
+// created or rescheduled in the meantime are picked up promptly
+const maxSleep = time.Minute
+
+// Config controls how many feeds are scraped at once and for how long
+type Config struct {
+	Concurrency int           // number of feeds scraped in parallel
+	RunTimeout  time.Duration // deadline for scraping a single feed
+	LeaseTTL    time.Duration // how long the scheduler lease outlives its last renewal
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Concurrency: 4,
+		RunTimeout:  2 * time.Minute,
+		LeaseTTL:    30 * time.Second,
+	}
+}
+
+// Scheduler scrapes each feed when its next_fetch_at comes due, using a
+// bounded pool of workers
+type Scheduler struct {
+	stores  store.Stores
+	fetcher *rss.Fetcher
+	config  Config
+}
+
+// New creates a Scheduler that scrapes feeds with the given fetcher
+func New(stores store.Stores, fetcher *rss.Fetcher, cfg Config) *Scheduler {
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	return &Scheduler{stores: stores, fetcher: fetcher, config: cfg}
+}
+
+// Run scrapes due feeds until ctx is cancelled, then returns once the
+// scrapes in flight have finished
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.runDue(ctx)
+
+		timer := time.NewTimer(s.untilNextDue(ctx))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// runDue scrapes every feed whose next fetch time has passed and logs a
+// summary of the cycle
+func (s *Scheduler) runDue(ctx context.Context) {
+	feeds, err := s.dueFeeds(ctx)
+	if err != nil {
+		log.Printf("Failed to fetch due feeds: %v", err)
+		return
+	}
+	if len(feeds) == 0 {
+		return
+	}
+
+	log.Printf("Running scheduled scrape of %d due feeds with %d workers", len(feeds), s.config.Concurrency)
+	var stats cycleStats
+	start := time.Now()
+
+	jobs := make(chan models.Feed)
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feed := range jobs {
+				s.scrape(ctx, feed, &stats)
+			}
+		}()
+	}
+
+dispatch:
+	for _, feed := range feeds {
+		select {
+		case jobs <- feed:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	stats.record(time.Since(start))
+	log.Printf("Scheduled scrape finished in %v: %d attempted, %d succeeded, %d failed, %d new items",
+		time.Since(start), stats.attempted.Load(), stats.succeeded.Load(), stats.failed.Load(), stats.newItems.Load())
+}
+
+// scrape runs a single feed under the per-run deadline and reschedules it
+func (s *Scheduler) scrape(ctx context.Context, feed models.Feed, stats *cycleStats) {
+	if ctx.Err() != nil {
+		return
+	}
+	stats.attempted.Add(1)
+
+	// A scrape that has started is finished even if ctx is cancelled by
+	// shutdown or a lost lease, so it never stops halfway through storing
+	// items; ingestion is idempotent, so overlapping a new leader is harmless
+	workCtx := context.WithoutCancel(ctx)
+	runCtx, cancel := context.WithTimeout(workCtx, s.config.RunTimeout)
+	defer cancel()
+
+	log.Printf("Scraping feed %s", feed.ID.Hex())
+	newItemsCount, _, err := handlers.ScrapeFeedLogic(runCtx, s.stores, s.fetcher, feed.ID.Hex())
+	if err != nil {
+		stats.failed.Add(1)
+		log.Printf("Failed to scrape feed %s: %v", feed.ID.Hex(), err)
+	} else {
+		stats.succeeded.Add(1)
+		stats.newItems.Add(int64(newItemsCount))
+		log.Printf("Scraped feed %s, added %d new items", feed.ID.Hex(), newItemsCount)
+	}
+
+	// Reload to pick up the polling hints stored by the scrape
+	if updated, err := s.loadFeed(workCtx, feed); err == nil {
+		feed = updated
+	}
+	interval, next := nextPoll(feed, newItemsCount, err == nil, time.Now())
+	if retryAt, ok := retryAfter(err); ok && retryAt.After(next) {
+		next = retryAt
+	}
+	s.reschedule(workCtx, feed, interval, next)
+}
+
+// loadFeed re-reads a feed from the database
+func (s *Scheduler) loadFeed(ctx context.Context, feed models.Feed) (models.Feed, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return s.stores.Feeds.Get(ctx, feed.ID)
+}
+
+// dueFeeds returns the active feeds that have never been fetched or whose
+// next fetch time has passed
+func (s *Scheduler) dueFeeds(ctx context.Context) ([]models.Feed, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return s.stores.Feeds.Due(ctx, time.Now())
+}
+
+// untilNextDue returns how long to sleep before the earliest scheduled feed
+// comes due, capped at maxSleep
+func (s *Scheduler) untilNextDue(ctx context.Context) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	next, err := s.stores.Feeds.NextDue(ctx)
+	if err != nil || next == nil {
+		return maxSleep
+	}
+
+	wait := time.Until(*next)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	if wait > maxSleep {
+		wait = maxSleep
+	}
+	return wait
+}
+
+// reschedule records the interval in use and when a feed should next be fetched
+func (s *Scheduler) reschedule(ctx context.Context, feed models.Feed, interval time.Duration, next time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := s.stores.Feeds.Reschedule(ctx, feed.ID, int(interval.Seconds()), next); err != nil {
+		log.Printf("Failed to reschedule feed %s: %v", feed.ID.Hex(), err)
+	}
+}
@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/handlers"
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/rss"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAdaptInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		feed     models.Feed
+		newItems int
+		want     time.Duration
+	}{
+		{"first fetch with items", models.Feed{}, 3, 30 * time.Minute},
+		{"first fetch without items", models.Feed{}, 0, 2 * time.Hour},
+		{"floored at the minimum", models.Feed{CurrentInterval: 360}, 1, handlers.MinPollInterval},
+		{"capped at the maximum", models.Feed{CurrentInterval: 86400}, 0, handlers.MaxPollInterval},
+		{"publisher hint", models.Feed{CurrentInterval: 3600, Hints: &models.PollingHints{MinInterval: 7200}}, 1, 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := adaptInterval(tt.feed, tt.newItems); got != tt.want {
+				t.Errorf("adaptInterval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextPoll(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		feed     models.Feed
+		newItems int
+		fetched  bool
+		want     time.Duration
+	}{
+		{"adapts after a fetch", models.Feed{CurrentInterval: 3600}, 0, true, 2 * time.Hour},
+		{"keeps the interval after a failure", models.Feed{CurrentInterval: 3600}, 0, false, time.Hour},
+		{"default after a first failure", models.Feed{}, 0, false, handlers.DefaultPollInterval},
+		{"explicit interval", models.Feed{PollInterval: 600, CurrentInterval: 3600}, 5, true, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval, next := nextPoll(tt.feed, tt.newItems, tt.fetched, now)
+			if interval != tt.want || !next.Equal(now.Add(tt.want)) {
+				t.Errorf("nextPoll = %v, %v; want %v", interval, next, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Test</title>
+<item><title>One</title><link>http://example.com/one</link></item>
+</channel></rss>`)
+	}))
+	defer server.Close()
+	defer handlers.WaitForNotifications()
+
+	cfg := rss.DefaultFetcherConfig()
+	cfg.HostDelay = 0
+	fetcher, err := rss.NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := store.NewMemoryStores()
+	ctx := context.Background()
+	later := time.Now().Add(time.Hour)
+	due := models.Feed{ID: primitive.NewObjectID(), Name: "Due", Url: server.URL, CreatedAt: time.Now()}
+	notDue := models.Feed{ID: primitive.NewObjectID(), Name: "Not due", Url: server.URL, NextFetchAt: &later, CreatedAt: time.Now()}
+	paused := models.Feed{ID: primitive.NewObjectID(), Name: "Paused", Url: server.URL, Paused: true, CreatedAt: time.Now()}
+	for _, feed := range []models.Feed{due, notDue, paused} {
+		if err := stores.Feeds.Create(ctx, feed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := time.Now()
+	New(stores, fetcher, DefaultConfig()).runDue(ctx)
+
+	scraped, err := stores.Feeds.Get(ctx, due.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scraped.NextFetchAt == nil || !scraped.NextFetchAt.After(before) || scraped.CurrentInterval != 1800 {
+		t.Fatalf("due feed not rescheduled: next %v, interval %d", scraped.NextFetchAt, scraped.CurrentInterval)
+	}
+
+	for _, feed := range []models.Feed{due, notDue, paused} {
+		items, err := stores.Items.ListByFeed(ctx, feed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		if feed.ID == due.ID {
+			want = 1
+		}
+		if len(items) != want {
+			t.Errorf("%s feed has %d items, want %d", feed.Name, len(items), want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package scheduler
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// Cumulative scheduler metrics, published at /debug/vars
+var metrics = expvar.NewMap("scheduler")
+
+// cycleStats counts the outcome of one scheduling cycle
+type cycleStats struct {
+	attempted atomic.Int64
+	succeeded atomic.Int64
+	failed    atomic.Int64
+	newItems  atomic.Int64
+}
+
+// record adds a finished cycle to the published metrics
+func (c *cycleStats) record(elapsed time.Duration) {
+	metrics.Add("cycles", 1)
+	metrics.Add("feeds_attempted", c.attempted.Load())
+	metrics.Add("feeds_succeeded", c.succeeded.Load())
+	metrics.Add("feeds_failed", c.failed.Load())
+	metrics.Add("new_items", c.newItems.Load())
+
+	last := new(expvar.Int)
+	last.Set(elapsed.Milliseconds())
+	metrics.Set("last_cycle_ms", last)
+}
@@ -0,0 +1,263 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FeedStore is the SQL store.FeedStore, backed by "feeds" and "scrape_runs"
+type FeedStore struct {
+	db *DB
+}
+
+const feedColumns = `id, name, url, user_id, created_at, updated_at,
+	poll_interval, current_interval, next_fetch_at, hints, etag, last_modified,
+	last_attempt_at, last_success_at, last_http_status, consecutive_failures,
+	last_error, last_error_code, last_error_at, paused, paused_at, retention`
+
+const runColumns = `id, feed_id, started_at, finished_at, duration_ms, http_status,
+	not_modified, bytes, items_parsed, items_new, items_bad_date, items_updated,
+	error, error_code`
+
+func (s *FeedStore) Create(ctx context.Context, feed models.Feed) error {
+	return s.write(ctx, s.db.conn(), "INSERT INTO feeds ("+feedColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, feed)
+}
+
+func (s *FeedStore) Get(ctx context.Context, id primitive.ObjectID) (models.Feed, error) {
+	return s.get(ctx, s.db.conn(), id)
+}
+
+func (s *FeedStore) Update(ctx context.Context, id primitive.ObjectID, settings store.FeedSettings) error {
+	retention, err := encodeJSON(settings.Retention)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE feeds SET name = ?, url = ?, user_id = ?, poll_interval = ?, retention = ?, updated_at = ?"
+	args := []interface{}{settings.Name, settings.Url, settings.UserID.Hex(), settings.PollInterval, retention, utc(settings.UpdatedAt)}
+	if settings.NextFetchAt != nil {
+		query += ", next_fetch_at = ?"
+		args = append(args, utc(*settings.NextFetchAt))
+	}
+	if settings.ResetFetchState {
+		query += `, hints = NULL, etag = '', last_modified = '', last_attempt_at = NULL, last_success_at = NULL,
+			last_http_status = 0, consecutive_failures = 0, last_error = '', last_error_code = '', last_error_at = NULL,
+			paused = ?, paused_at = NULL`
+		args = append(args, false)
+	}
+	_, err = s.db.conn().exec(ctx, query+" WHERE id = ?", append(args, id.Hex())...)
+	return err
+}
+
+func (s *FeedStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.db.conn().exec(ctx, "DELETE FROM feeds WHERE id = ?", id.Hex())
+	return err
+}
+
+func (s *FeedStore) List(ctx context.Context, filter store.FeedFilter) ([]models.Feed, error) {
+	var where string
+	var args []interface{}
+	switch filter.Health {
+	case store.HealthAny:
+		where = "1 = 1"
+	case store.HealthHealthy:
+		where, args = "consecutive_failures = 0 AND paused = ?", []interface{}{false}
+	case store.HealthUnhealthy:
+		where, args = "(consecutive_failures > 0 OR paused = ?)", []interface{}{true}
+	case store.HealthPaused:
+		where, args = "paused = ?", []interface{}{true}
+	default:
+		return nil, fmt.Errorf("unknown health filter %q", filter.Health)
+	}
+	return s.find(ctx, "SELECT "+feedColumns+" FROM feeds WHERE "+where+" ORDER BY created_at", args...)
+}
+
+func (s *FeedStore) RecordFetchSuccess(ctx context.Context, id primitive.ObjectID, success store.FetchSuccess) error {
+	query := `UPDATE feeds SET
+		etag = ?, last_modified = ?, last_attempt_at = ?, last_success_at = ?, last_http_status = ?,
+		consecutive_failures = 0, last_error = '', last_error_code = '', last_error_at = NULL,
+		paused = ?, paused_at = NULL`
+	args := []interface{}{success.ETag, success.LastModified, utc(success.At), utc(success.At), success.HTTPStatus, false}
+	if success.Hints != nil {
+		hints, err := encodeJSON(success.Hints)
+		if err != nil {
+			return err
+		}
+		query += ", hints = ?"
+		args = append(args, hints)
+	}
+	_, err := s.db.conn().exec(ctx, query+" WHERE id = ?", append(args, id.Hex())...)
+	return err
+}
+
+func (s *FeedStore) RecordFetchFailure(ctx context.Context, id primitive.ObjectID, failure store.FetchFailure) (models.Feed, error) {
+	row := s.db.conn().queryRow(ctx, `UPDATE feeds SET
+		last_attempt_at = ?, last_error = ?, last_error_code = ?, last_error_at = ?,
+		last_http_status = ?, consecutive_failures = consecutive_failures + 1
+		WHERE id = ?
+		RETURNING `+feedColumns,
+		utc(failure.At), failure.Error, failure.ErrorCode, utc(failure.At), failure.HTTPStatus, id.Hex())
+	return scanFeed(row)
+}
+
+func (s *FeedStore) Pause(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := s.db.conn().exec(ctx, "UPDATE feeds SET paused = ?, paused_at = ? WHERE id = ?", true, utc(at), id.Hex())
+	return err
+}
+
+func (s *FeedStore) Due(ctx context.Context, now time.Time) ([]models.Feed, error) {
+	// Paused feeds have failed too often and are only retried manually
+	return s.find(ctx, "SELECT "+feedColumns+` FROM feeds
+		WHERE paused = ? AND (next_fetch_at IS NULL OR next_fetch_at <= ?)
+		ORDER BY next_fetch_at NULLS FIRST`, false, utc(now))
+}
+
+func (s *FeedStore) NextDue(ctx context.Context) (*time.Time, error) {
+	var next time.Time
+	err := s.db.conn().queryRow(ctx, `SELECT next_fetch_at FROM feeds
+		WHERE paused = ? AND next_fetch_at IS NOT NULL
+		ORDER BY next_fetch_at LIMIT 1`, false).Scan(&next)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+func (s *FeedStore) Reschedule(ctx context.Context, id primitive.ObjectID, interval int, next time.Time) error {
+	_, err := s.db.conn().exec(ctx, "UPDATE feeds SET current_interval = ?, next_fetch_at = ? WHERE id = ?",
+		interval, utc(next), id.Hex())
+	return err
+}
+
+// RecordRun stores a run and drops the feed's runs older than
+// store.ScrapeRunRetention, which MongoDB would have expired
+func (s *FeedStore) RecordRun(ctx context.Context, run models.ScrapeRun) error {
+	return s.db.inTx(ctx, func(tx conn) error {
+		_, err := tx.exec(ctx, "INSERT INTO scrape_runs ("+runColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			run.ID.Hex(), run.FeedID.Hex(), utc(run.StartedAt), utc(run.FinishedAt), run.DurationMs, run.HTTPStatus,
+			run.NotModified, run.Bytes, run.ItemsParsed, run.ItemsNew, run.ItemsBadDate, run.ItemsUpdated,
+			run.Error, run.ErrorCode)
+		if err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, "DELETE FROM scrape_runs WHERE feed_id = ? AND started_at < ?",
+			run.FeedID.Hex(), utc(time.Now().Add(-store.ScrapeRunRetention)))
+		return err
+	})
+}
+
+func (s *FeedStore) ListRuns(ctx context.Context, feedID primitive.ObjectID, limit, offset int64) ([]models.ScrapeRun, int64, error) {
+	var total int64
+	err := s.db.conn().queryRow(ctx, "SELECT COUNT(*) FROM scrape_runs WHERE feed_id = ?", feedID.Hex()).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.conn().query(ctx, "SELECT "+runColumns+` FROM scrape_runs
+		WHERE feed_id = ? ORDER BY started_at DESC LIMIT ? OFFSET ?`, feedID.Hex(), limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	runs := []models.ScrapeRun{}
+	for rows.Next() {
+		var run models.ScrapeRun
+		var id, feed string
+		err := rows.Scan(&id, &feed, &run.StartedAt, &run.FinishedAt, &run.DurationMs, &run.HTTPStatus,
+			&run.NotModified, &run.Bytes, &run.ItemsParsed, &run.ItemsNew, &run.ItemsBadDate, &run.ItemsUpdated,
+			&run.Error, &run.ErrorCode)
+		if err != nil {
+			return nil, 0, err
+		}
+		run.ID, run.FeedID = parseID(id), parseID(feed)
+		runs = append(runs, run)
+	}
+	return runs, total, rows.Err()
+}
+
+func (s *FeedStore) get(ctx context.Context, c conn, id primitive.ObjectID) (models.Feed, error) {
+	return scanFeed(c.queryRow(ctx, "SELECT "+feedColumns+" FROM feeds WHERE id = ?", id.Hex()))
+}
+
+func (s *FeedStore) find(ctx context.Context, query string, args ...interface{}) ([]models.Feed, error) {
+	rows, err := s.db.conn().query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feeds []models.Feed
+	for rows.Next() {
+		feed, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, rows.Err()
+}
+
+// write runs an INSERT or UPDATE taking the feed's columns in order,
+// followed by extra arguments
+func (s *FeedStore) write(ctx context.Context, c conn, query string, feed models.Feed, extra ...interface{}) error {
+	hints, err := encodeJSON(feed.Hints)
+	if err != nil {
+		return err
+	}
+	retention, err := encodeJSON(feed.Retention)
+	if err != nil {
+		return err
+	}
+	args := []interface{}{
+		feed.ID.Hex(), feed.Name, feed.Url, feed.UserID.Hex(), utc(feed.CreatedAt), utc(feed.UpdatedAt),
+		feed.PollInterval, feed.CurrentInterval, nullTime(feed.NextFetchAt), hints, feed.ETag, feed.LastModified,
+		nullTime(feed.LastAttemptAt), nullTime(feed.LastSuccessAt), feed.LastHTTPStatus, feed.ConsecutiveFailures,
+		feed.LastError, feed.LastErrorCode, nullTime(feed.LastErrorAt), feed.Paused, nullTime(feed.PausedAt), retention,
+	}
+	_, err = c.exec(ctx, query, append(args, extra...)...)
+	if err != nil && s.db.dialect.uniqueViolation(err) {
+		return store.ErrDuplicate
+	}
+	return err
+}
+
+// scanFeed reads a row of feedColumns
+func scanFeed(row scanner) (models.Feed, error) {
+	var feed models.Feed
+	var id, userID string
+	var hints, retention sql.NullString
+	var nextFetchAt, lastAttemptAt, lastSuccessAt, lastErrorAt, pausedAt sql.NullTime
+	err := row.Scan(
+		&id, &feed.Name, &feed.Url, &userID, &feed.CreatedAt, &feed.UpdatedAt,
+		&feed.PollInterval, &feed.CurrentInterval, &nextFetchAt, &hints, &feed.ETag, &feed.LastModified,
+		&lastAttemptAt, &lastSuccessAt, &feed.LastHTTPStatus, &feed.ConsecutiveFailures,
+		&feed.LastError, &feed.LastErrorCode, &lastErrorAt, &feed.Paused, &pausedAt, &retention,
+	)
+	if err != nil {
+		return models.Feed{}, notFound(err)
+	}
+	feed.ID, feed.UserID = parseID(id), parseID(userID)
+	feed.NextFetchAt = timePtr(nextFetchAt)
+	feed.LastAttemptAt = timePtr(lastAttemptAt)
+	feed.LastSuccessAt = timePtr(lastSuccessAt)
+	feed.LastErrorAt = timePtr(lastErrorAt)
+	feed.PausedAt = timePtr(pausedAt)
+	if err := decodeJSON(hints, &feed.Hints); err != nil {
+		return models.Feed{}, err
+	}
+	if err := decodeJSON(retention, &feed.Retention); err != nil {
+		return models.Feed{}, err
+	}
+	return feed, nil
+}
@@ -0,0 +1,83 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FollowerStore is the SQL store.FollowerStore, backed by "feed_followers"
+type FollowerStore struct {
+	db *DB
+}
+
+const followerColumns = "id, feed_id, user_id, created_at"
+
+// Follow relies on the unique (feed_id, user_id) constraint to refuse a
+// second subscription, and on the foreign key to refuse unknown feeds
+func (s *FollowerStore) Follow(ctx context.Context, follower models.FeedFollower) error {
+	_, err := s.db.conn().exec(ctx, "INSERT INTO feed_followers ("+followerColumns+") VALUES (?, ?, ?, ?)",
+		follower.ID.Hex(), follower.FeedID.Hex(), follower.UserID, utc(follower.CreatedAt))
+	switch {
+	case err == nil:
+		return nil
+	case s.db.dialect.uniqueViolation(err):
+		return store.ErrDuplicate
+	case s.db.dialect.foreignKeyViolation(err):
+		return store.ErrNotFound
+	}
+	return err
+}
+
+func (s *FollowerStore) Unfollow(ctx context.Context, feedID primitive.ObjectID, userID string) error {
+	result, err := s.db.conn().exec(ctx, "DELETE FROM feed_followers WHERE feed_id = ? AND user_id = ?", feedID.Hex(), userID)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *FollowerStore) ListByUser(ctx context.Context, userID string) ([]models.FeedFollower, error) {
+	return s.find(ctx, "SELECT "+followerColumns+" FROM feed_followers WHERE user_id = ? ORDER BY created_at", userID)
+}
+
+func (s *FollowerStore) ListByFeed(ctx context.Context, feedID primitive.ObjectID) ([]models.FeedFollower, error) {
+	return s.find(ctx, "SELECT "+followerColumns+" FROM feed_followers WHERE feed_id = ? ORDER BY created_at", feedID.Hex())
+}
+
+func (s *FollowerStore) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := s.db.conn().exec(ctx, "DELETE FROM feed_followers WHERE user_id = ?", userID)
+	return err
+}
+
+func (s *FollowerStore) DeleteByFeed(ctx context.Context, feedID primitive.ObjectID) error {
+	_, err := s.db.conn().exec(ctx, "DELETE FROM feed_followers WHERE feed_id = ?", feedID.Hex())
+	return err
+}
+
+func (s *FollowerStore) find(ctx context.Context, query string, args ...interface{}) ([]models.FeedFollower, error) {
+	rows, err := s.db.conn().query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var followers []models.FeedFollower
+	for rows.Next() {
+		var follower models.FeedFollower
+		var id, feedID string
+		if err := rows.Scan(&id, &feedID, &follower.UserID, &follower.CreatedAt); err != nil {
+			return nil, err
+		}
+		follower.ID, follower.FeedID = parseID(id), parseID(feedID)
+		followers = append(followers, follower)
+	}
+	return followers, rows.Err()
+}
@@ -0,0 +1,368 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/rss"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ItemStore is the SQL store.ItemStore, backed by "feed_items" and
+// "item_revisions"
+type ItemStore struct {
+	db *DB
+}
+
+const itemColumns = `id, feed_id, guid, identity_key, title, link, description, pub_date,
+	author, categories, enclosures, content_hash, revision, updated_at, starred`
+
+const revisionColumns = `id, item_id, feed_id, revision, title, link, description,
+	author, categories, enclosures, content_hash, replaced_at`
+
+// InsertNew inserts every item unless its identity key, or the legacy link
+// key of an item stored before the feed sent GUIDs, is already taken. The
+// unique (feed_id, identity_key) constraint settles races with an
+// overlapping scrape of the same feed.
+func (s *ItemStore) InsertNew(ctx context.Context, items []models.FeedItem) ([]models.FeedItem, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	var inserted []models.FeedItem
+	err := s.db.inTx(ctx, func(tx conn) error {
+		inserted = nil
+		for _, item := range items {
+			if legacyKey := legacyLinkKey(item); legacyKey != "" {
+				var exists int
+				err := tx.queryRow(ctx, "SELECT 1 FROM feed_items WHERE feed_id = ? AND identity_key = ? AND guid = ''",
+					item.FeedID.Hex(), legacyKey).Scan(&exists)
+				if err == nil {
+					continue
+				}
+				if err != sql.ErrNoRows {
+					return err
+				}
+			}
+
+			if item.ID.IsZero() {
+				item.ID = primitive.NewObjectID()
+			}
+			args, err := itemArgs(item)
+			if err != nil {
+				return err
+			}
+			result, err := tx.exec(ctx, "INSERT INTO feed_items ("+itemColumns+`)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (feed_id, identity_key) DO NOTHING`, args...)
+			if err != nil {
+				return err
+			}
+			if count, err := result.RowsAffected(); err != nil {
+				return err
+			} else if count > 0 {
+				inserted = append(inserted, item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+func (s *ItemStore) FindByKeys(ctx context.Context, feedID primitive.ObjectID, keys []string) ([]models.FeedItem, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{feedID.Hex()}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	return s.find(ctx, "SELECT "+itemColumns+" FROM feed_items WHERE feed_id = ? AND identity_key IN ("+placeholders(len(keys))+")", args...)
+}
+
+func (s *ItemStore) ApplyEdit(ctx context.Context, previous, updated models.FeedItem, revision models.ItemRevision) (bool, error) {
+	categories, err := encodeJSON(updated.Categories)
+	if err != nil {
+		return false, err
+	}
+	enclosures, err := encodeJSON(updated.Enclosures)
+	if err != nil {
+		return false, err
+	}
+	applied := false
+	err = s.db.inTx(ctx, func(tx conn) error {
+		// Only update the content we compared against, so two overlapping
+		// scrapes cannot both record the same edit
+		result, err := tx.exec(ctx, `UPDATE feed_items SET
+			title = ?, link = ?, description = ?, author = ?, categories = ?, enclosures = ?,
+			content_hash = ?, updated_at = ?, revision = revision + 1
+			WHERE id = ? AND content_hash = ?`,
+			updated.Title, updated.Link, updated.Description, updated.Author, categories, enclosures,
+			updated.ContentHash, nullTime(updated.UpdatedAt), previous.ID.Hex(), previous.ContentHash)
+		if err != nil {
+			return err
+		}
+		if count, err := result.RowsAffected(); err != nil || count == 0 {
+			return err
+		}
+		if err := insertRevision(ctx, tx, revision); err != nil {
+			return err
+		}
+		applied = true
+		return nil
+	})
+	return applied, err
+}
+
+func (s *ItemStore) Get(ctx context.Context, id primitive.ObjectID) (models.FeedItem, error) {
+	return scanItem(s.db.conn().queryRow(ctx, "SELECT "+itemColumns+" FROM feed_items WHERE id = ?", id.Hex()))
+}
+
+func (s *ItemStore) ListByFeed(ctx context.Context, feedID primitive.ObjectID) ([]models.FeedItem, error) {
+	return s.find(ctx, "SELECT "+itemColumns+" FROM feed_items WHERE feed_id = ? ORDER BY pub_date DESC", feedID.Hex())
+}
+
+func (s *ItemStore) Revisions(ctx context.Context, itemID primitive.ObjectID) ([]models.ItemRevision, error) {
+	rows, err := s.db.conn().query(ctx, "SELECT "+revisionColumns+" FROM item_revisions WHERE item_id = ? ORDER BY revision DESC", itemID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.ItemRevision{}
+	for rows.Next() {
+		var revision models.ItemRevision
+		var id, item, feed string
+		var categories, enclosures sql.NullString
+		err := rows.Scan(&id, &item, &feed, &revision.Revision, &revision.Title, &revision.Link, &revision.Description,
+			&revision.Author, &categories, &enclosures, &revision.ContentHash, &revision.ReplacedAt)
+		if err != nil {
+			return nil, err
+		}
+		revision.ID, revision.ItemID, revision.FeedID = parseID(id), parseID(item), parseID(feed)
+		if err := decodeJSON(categories, &revision.Categories); err != nil {
+			return nil, err
+		}
+		if err := decodeJSON(enclosures, &revision.Enclosures); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *ItemStore) SetStarred(ctx context.Context, id primitive.ObjectID, starred bool) error {
+	result, err := s.db.conn().exec(ctx, "UPDATE feed_items SET starred = ? WHERE id = ?", starred, id.Hex())
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// Search ranks the items matching every word with the dialect's
+// full-text index
+func (s *ItemStore) Search(ctx context.Context, query string, feedID primitive.ObjectID, limit int64) ([]models.FeedItem, error) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	return s.db.dialect.searchItems(ctx, s.db, words, feedID, limit)
+}
+
+func (s *ItemStore) PrunableByAge(ctx context.Context, feedID primitive.ObjectID, cutoff time.Time) ([]primitive.ObjectID, error) {
+	return s.ids(ctx, "SELECT id FROM feed_items WHERE feed_id = ? AND starred = ? AND pub_date < ?",
+		feedID.Hex(), false, utc(cutoff))
+}
+
+func (s *ItemStore) PrunableByCount(ctx context.Context, feedID primitive.ObjectID, keep int) ([]primitive.ObjectID, error) {
+	ids, err := s.ids(ctx, "SELECT id FROM feed_items WHERE feed_id = ? AND starred = ? ORDER BY pub_date DESC, id DESC",
+		feedID.Hex(), false)
+	if err != nil || len(ids) <= keep {
+		return nil, err
+	}
+	return ids[keep:], nil
+}
+
+func (s *ItemStore) FeedIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	return s.ids(ctx, "SELECT DISTINCT feed_id FROM feed_items")
+}
+
+func (s *ItemStore) CountByFeeds(ctx context.Context, feedIDs []primitive.ObjectID) (int64, error) {
+	if len(feedIDs) == 0 {
+		return 0, nil
+	}
+	var count int64
+	err := s.db.conn().queryRow(ctx, "SELECT COUNT(*) FROM feed_items WHERE feed_id IN ("+placeholders(len(feedIDs))+") AND starred = ?",
+		append(idArgs(feedIDs), false)...).Scan(&count)
+	return count, err
+}
+
+// Delete removes the unstarred items; their revisions go with them through
+// the foreign key
+func (s *ItemStore) Delete(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result, err := s.db.conn().exec(ctx, "DELETE FROM feed_items WHERE id IN ("+placeholders(len(ids))+") AND starred = ?",
+		append(idArgs(ids), false)...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *ItemStore) DeleteByFeed(ctx context.Context, feedID primitive.ObjectID) (int64, error) {
+	result, err := s.db.conn().exec(ctx, "DELETE FROM feed_items WHERE feed_id = ? AND starred = ?", feedID.Hex(), false)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *ItemStore) find(ctx context.Context, query string, args ...interface{}) ([]models.FeedItem, error) {
+	return findItems(ctx, s.db.conn(), query, args...)
+}
+
+// ids returns the IDs in the single column query selects
+func (s *ItemStore) ids(ctx context.Context, query string, args ...interface{}) ([]primitive.ObjectID, error) {
+	rows, err := s.db.conn().query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []primitive.ObjectID
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, parseID(id))
+	}
+	return ids, rows.Err()
+}
+
+// findItems runs a query selecting itemColumns
+func findItems(ctx context.Context, c conn, query string, args ...interface{}) ([]models.FeedItem, error) {
+	rows, err := c.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.FeedItem
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// rekeyFragmentLinks gives items keyed by a link with a fragment the key
+// they get today. Links used to be keyed without their fragment, so only
+// the first of several items linking to anchors of one page was stored.
+func rekeyFragmentLinks(ctx context.Context, tx conn) error {
+	rows, err := tx.query(ctx, "SELECT id, link, identity_key FROM feed_items WHERE identity_key LIKE 'link:%' AND link LIKE '%#%'")
+	if err != nil {
+		return err
+	}
+	rekeyed := map[string]string{}
+	for rows.Next() {
+		var id, link, key string
+		if err := rows.Scan(&id, &link, &key); err != nil {
+			rows.Close()
+			return err
+		}
+		if newKey := rss.IdentityKey("", link, "", ""); newKey != key {
+			rekeyed[id] = newKey
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, key := range rekeyed {
+		if _, err := tx.exec(ctx, "UPDATE feed_items SET identity_key = ? WHERE id = ?", key, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyLinkKey is the key an item would have been stored under before its
+// feed sent GUIDs, or "" if that cannot differ from its own key
+func legacyLinkKey(item models.FeedItem) string {
+	linkKey := "link:" + rss.NormalizeLink(item.Link)
+	if item.Link == "" || item.IdentityKey == linkKey {
+		return ""
+	}
+	return linkKey
+}
+
+// itemArgs returns an item's values in the order of itemColumns
+func itemArgs(item models.FeedItem) ([]interface{}, error) {
+	categories, err := encodeJSON(item.Categories)
+	if err != nil {
+		return nil, err
+	}
+	enclosures, err := encodeJSON(item.Enclosures)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		item.ID.Hex(), item.FeedID.Hex(), item.GUID, item.IdentityKey, item.Title, item.Link, item.Description, utc(item.PubDate),
+		item.Author, categories, enclosures, item.ContentHash, item.Revision, nullTime(item.UpdatedAt), item.Starred,
+	}, nil
+}
+
+func insertRevision(ctx context.Context, c conn, revision models.ItemRevision) error {
+	categories, err := encodeJSON(revision.Categories)
+	if err != nil {
+		return err
+	}
+	enclosures, err := encodeJSON(revision.Enclosures)
+	if err != nil {
+		return err
+	}
+	_, err = c.exec(ctx, "INSERT INTO item_revisions ("+revisionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		revision.ID.Hex(), revision.ItemID.Hex(), revision.FeedID.Hex(), revision.Revision, revision.Title, revision.Link,
+		revision.Description, revision.Author, categories, enclosures, revision.ContentHash, utc(revision.ReplacedAt))
+	return err
+}
+
+// scanItem reads a row of itemColumns
+func scanItem(row scanner) (models.FeedItem, error) {
+	var item models.FeedItem
+	var id, feedID string
+	var categories, enclosures sql.NullString
+	var updatedAt sql.NullTime
+	err := row.Scan(&id, &feedID, &item.GUID, &item.IdentityKey, &item.Title, &item.Link, &item.Description, &item.PubDate,
+		&item.Author, &categories, &enclosures, &item.ContentHash, &item.Revision, &updatedAt, &item.Starred)
+	if err != nil {
+		return models.FeedItem{}, notFound(err)
+	}
+	item.ID, item.FeedID = parseID(id), parseID(feedID)
+	item.UpdatedAt = timePtr(updatedAt)
+	if err := decodeJSON(categories, &item.Categories); err != nil {
+		return models.FeedItem{}, err
+	}
+	if err := decodeJSON(enclosures, &item.Enclosures); err != nil {
+		return models.FeedItem{}, err
+	}
+	return item, nil
+}
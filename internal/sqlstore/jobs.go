@@ -0,0 +1,108 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/jobs"
+	"github.com/kwabena369/scrapper/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobStore is the SQL jobs.Store, backed by "scrape_jobs". A unique
+// pending_key column keeps one queued or running job per feed, as the
+// MongoDB store's sparse index does.
+type JobStore struct {
+	db *DB
+}
+
+const jobColumns = `id, feed_id, status, created_at, started_at, finished_at,
+	attempts, new_items, error, error_code, worker, pending_key`
+
+func (s *JobStore) Enqueue(ctx context.Context, feedID primitive.ObjectID) (job *models.ScrapeJob, coalesced bool, err error) {
+	newID := primitive.NewObjectID()
+	// A pending job can finish between the insert and the select; the
+	// retry then queues a new one
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err = s.db.conn().exec(ctx, "INSERT INTO scrape_jobs ("+jobColumns+`)
+			VALUES (?, ?, ?, ?, NULL, NULL, 0, 0, '', '', '', ?)
+			ON CONFLICT (pending_key) DO NOTHING`,
+			newID.Hex(), feedID.Hex(), models.JobQueued, utc(time.Now()), feedID.Hex())
+		if err != nil {
+			return nil, false, err
+		}
+		job, err = scanJob(s.db.conn().queryRow(ctx, "SELECT "+jobColumns+" FROM scrape_jobs WHERE pending_key = ?", feedID.Hex()))
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return job, job.ID != newID, nil
+	}
+	return nil, false, err
+}
+
+func (s *JobStore) Get(ctx context.Context, id primitive.ObjectID) (*models.ScrapeJob, error) {
+	return scanJob(s.db.conn().queryRow(ctx, "SELECT "+jobColumns+" FROM scrape_jobs WHERE id = ?", id.Hex()))
+}
+
+func (s *JobStore) Claim(ctx context.Context, worker string, staleAfter time.Duration) (*models.ScrapeJob, error) {
+	now := time.Now()
+	// The claim condition is repeated outside the subquery so a worker
+	// that lost a race for the same job updates nothing
+	const claimable = "(status = ? OR (status = ? AND started_at < ?))"
+	stale := utc(now.Add(-staleAfter))
+	job, err := scanJob(s.db.conn().queryRow(ctx, `UPDATE scrape_jobs
+		SET status = ?, started_at = ?, worker = ?, attempts = attempts + 1
+		WHERE id = (SELECT id FROM scrape_jobs WHERE `+claimable+` ORDER BY created_at LIMIT 1)
+		AND `+claimable+`
+		RETURNING `+jobColumns,
+		models.JobRunning, utc(now), worker,
+		models.JobQueued, models.JobRunning, stale,
+		models.JobQueued, models.JobRunning, stale))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// Complete also drops jobs finished more than jobs.Retention ago, which
+// MongoDB would have expired
+func (s *JobStore) Complete(ctx context.Context, job *models.ScrapeJob, newItems int, errMsg, errCode string) error {
+	status := models.JobSucceeded
+	if errMsg != "" {
+		status = models.JobFailed
+	}
+	now := time.Now()
+	return s.db.inTx(ctx, func(tx conn) error {
+		// Only the worker that currently owns the job may finish it
+		_, err := tx.exec(ctx, `UPDATE scrape_jobs
+			SET status = ?, finished_at = ?, new_items = ?, error = ?, error_code = ?, pending_key = NULL
+			WHERE id = ? AND worker = ? AND status = ?`,
+			status, utc(now), newItems, errMsg, errCode, job.ID.Hex(), job.Worker, models.JobRunning)
+		if err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, "DELETE FROM scrape_jobs WHERE finished_at < ?", utc(now.Add(-jobs.Retention)))
+		return err
+	})
+}
+
+// scanJob reads a row of jobColumns
+func scanJob(row scanner) (*models.ScrapeJob, error) {
+	var job models.ScrapeJob
+	var id, feedID string
+	var startedAt, finishedAt sql.NullTime
+	var pendingKey sql.NullString
+	err := row.Scan(&id, &feedID, &job.Status, &job.CreatedAt, &startedAt, &finishedAt,
+		&job.Attempts, &job.NewItems, &job.Error, &job.ErrorCode, &job.Worker, &pendingKey)
+	if err != nil {
+		return nil, err
+	}
+	job.ID, job.FeedID = parseID(id), parseID(feedID)
+	job.StartedAt, job.FinishedAt = timePtr(startedAt), timePtr(finishedAt)
+	job.PendingKey = pendingKey.String
+	return &job, nil
+}
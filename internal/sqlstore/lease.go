@@ -0,0 +1,62 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/lease"
+)
+
+// Lease is a lease.Lease stored in the PostgreSQL "leases" table. Expiry is
+// judged by the database server's clock so instances need not agree on the
+// time. A SQLite database serves a single instance and has no leases.
+type Lease struct {
+	db     *DB
+	name   string
+	holder string
+	ttl    time.Duration
+}
+
+// NewLease returns a handle on the named lease for this process
+func (d *DB) NewLease(name string, ttl time.Duration) *Lease {
+	return &Lease{db: d, name: name, holder: lease.HolderID(), ttl: ttl}
+}
+
+func (l *Lease) Name() string {
+	return l.name
+}
+
+func (l *Lease) Holder() string {
+	return l.holder
+}
+
+func (l *Lease) TTL() time.Duration {
+	return l.ttl
+}
+
+func (l *Lease) TryAcquire(ctx context.Context) (bool, error) {
+	// The conflict update only applies when this process already holds
+	// the lease or it has expired; otherwise no row is affected
+	result, err := l.db.conn().exec(ctx, `INSERT INTO leases (name, holder, acquired_at, renewed_at, expires_at)
+		VALUES (?, ?, now(), now(), now() + make_interval(secs => ?))
+		ON CONFLICT (name) DO UPDATE SET
+			holder = excluded.holder,
+			acquired_at = CASE WHEN leases.holder = excluded.holder THEN leases.acquired_at ELSE excluded.acquired_at END,
+			renewed_at = excluded.renewed_at,
+			expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at < excluded.renewed_at`,
+		l.name, l.holder, l.ttl.Seconds())
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+func (l *Lease) Release(ctx context.Context) error {
+	_, err := l.db.conn().exec(ctx, "DELETE FROM leases WHERE name = ? AND holder = ?", l.name, l.holder)
+	return err
+}
+
+// Leases implement lease.Lease
+var _ lease.Lease = (*Lease)(nil)
@@ -0,0 +1,153 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Migration is one versioned change to the schema. Its statements, then its
+// backfill, run in a single transaction together with its record, so a
+// failed migration leaves nothing behind.
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+	// Backfill, if set, rewrites stored rows in ways SQL cannot express
+	Backfill func(ctx context.Context, tx conn) error
+}
+
+// MigrationRecord is the row stored in "schema_migrations" once a
+// migration has been applied
+type MigrationRecord struct {
+	Version     int
+	Description string
+	AppliedAt   time.Time
+	DurationMs  int64
+}
+
+// MigrationStatus pairs a known migration with its record, if applied
+type MigrationStatus struct {
+	Migration
+	Applied *MigrationRecord
+}
+
+// createMigrationsTable creates the "schema_migrations" table shared by
+// every dialect
+func (d *DB) createMigrationsTable(ctx context.Context, c conn) error {
+	if err := d.lockMigrations(ctx, c); err != nil {
+		return err
+	}
+	_, err := c.exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version     INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at  TIMESTAMP NOT NULL,
+	duration_ms BIGINT NOT NULL
+)`)
+	return err
+}
+
+// lockMigrations takes the dialect's migration lock, if it has one
+func (d *DB) lockMigrations(ctx context.Context, c conn) error {
+	if d.dialect.migrationLock == "" {
+		return nil
+	}
+	_, err := c.exec(ctx, d.dialect.migrationLock)
+	return err
+}
+
+// Migrate applies every migration that has not been recorded yet, in
+// version order, and returns the ones it applied. It then sets up item
+// search, which depends on how the database was built.
+func (d *DB) Migrate(ctx context.Context) ([]Migration, error) {
+	err := d.inTx(ctx, func(tx conn) error {
+		return d.createMigrationsTable(ctx, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range d.dialect.migrations {
+		start := time.Now()
+		applied := false
+		err := d.inTx(ctx, func(tx conn) error {
+			if err := d.lockMigrations(ctx, tx); err != nil {
+				return err
+			}
+			// Re-checked inside the transaction, as another instance may
+			// have applied the migration since this one started
+			var version int
+			err := tx.queryRow(ctx, "SELECT version FROM schema_migrations WHERE version = ?", migration.Version).Scan(&version)
+			if err == nil {
+				return nil
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+			for _, statement := range migration.Statements {
+				if _, err := tx.exec(ctx, statement); err != nil {
+					return err
+				}
+			}
+			if migration.Backfill != nil {
+				if err := migration.Backfill(ctx, tx); err != nil {
+					return err
+				}
+			}
+			_, err = tx.exec(ctx,
+				"INSERT INTO schema_migrations (version, description, applied_at, duration_ms) VALUES (?, ?, ?, ?)",
+				migration.Version, migration.Description, utc(time.Now()), time.Since(start).Milliseconds())
+			applied = err == nil
+			return err
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		if applied {
+			log.Printf("Applied migration %d (%s) in %v", migration.Version, migration.Description, time.Since(start))
+			ran = append(ran, migration)
+		}
+	}
+
+	if err := d.dialect.prepareSearch(ctx, d); err != nil {
+		return ran, fmt.Errorf("prepare item search: %w", err)
+	}
+	return ran, nil
+}
+
+// Status reports every known migration and whether it has been applied
+func (d *DB) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := d.createMigrationsTable(ctx, d.conn()); err != nil {
+		return nil, err
+	}
+	rows, err := d.conn().query(ctx, "SELECT version, description, applied_at, duration_ms FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationRecord)
+	for rows.Next() {
+		var record MigrationRecord
+		if err := rows.Scan(&record.Version, &record.Description, &record.AppliedAt, &record.DurationMs); err != nil {
+			return nil, err
+		}
+		applied[record.Version] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(d.dialect.migrations))
+	for _, migration := range d.dialect.migrations {
+		status := MigrationStatus{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = &record
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,36 @@
+//go:build !sqlite_fts5
+
+package sqlstore
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenSQLiteRequiresFTS5(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := OpenSQLite(path)
+	if err == nil {
+		db.Close()
+		t.Fatal("OpenSQLite succeeded in a build without FTS5")
+	}
+	if !strings.Contains(err.Error(), "sqlite_fts5") {
+		t.Errorf("error %q does not name the build tag", err)
+	}
+
+	// Nothing was migrated, so a rebuilt binary starts from a clean file
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	var tables int
+	if err := raw.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("%d tables created before the FTS5 check", tables)
+	}
+}
@@ -0,0 +1,236 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OpenPostgres connects to the PostgreSQL database at url, a postgres://
+// URL or key=value connection string. Call Migrate before using its
+// stores.
+func OpenPostgres(url string) (*DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{db: db, dialect: postgresDialect}, nil
+}
+
+// postgresMigrationLock is the advisory lock key held while migrating
+const postgresMigrationLock = 7283390
+
+var postgresDialect = &dialect{
+	migrations:    postgresMigrations,
+	rebind:        rebindDollar,
+	migrationLock: "SELECT pg_advisory_xact_lock(" + strconv.Itoa(postgresMigrationLock) + ")",
+	uniqueViolation: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == "23505"
+	},
+	foreignKeyViolation: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == "23503"
+	},
+	// The search column is part of the schema, so it is always available
+	prepareSearch: func(ctx context.Context, d *DB) error { return nil },
+	searchItems:   searchPostgresItems,
+}
+
+// postgresMigrations lists the PostgreSQL schema in version order. New
+// migrations are appended with the next version; released ones are never
+// edited.
+var postgresMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create users, feeds, scrape_runs, feed_items, item_revisions, feed_followers, scrape_jobs and leases",
+		Statements: []string{
+			`CREATE TABLE users (
+				id           TEXT PRIMARY KEY,
+				firebase_uid TEXT NOT NULL,
+				username     TEXT NOT NULL,
+				email        TEXT NOT NULL,
+				created_at   TIMESTAMPTZ NOT NULL,
+				updated_at   TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX users_firebase_uid ON users (firebase_uid)`,
+
+			`CREATE TABLE feeds (
+				id                   TEXT PRIMARY KEY,
+				name                 TEXT NOT NULL,
+				url                  TEXT NOT NULL,
+				user_id              TEXT NOT NULL,
+				created_at           TIMESTAMPTZ NOT NULL,
+				updated_at           TIMESTAMPTZ NOT NULL,
+				poll_interval        INTEGER NOT NULL DEFAULT 0,
+				current_interval     INTEGER NOT NULL DEFAULT 0,
+				next_fetch_at        TIMESTAMPTZ,
+				hints                JSONB,
+				etag                 TEXT NOT NULL DEFAULT '',
+				last_modified        TEXT NOT NULL DEFAULT '',
+				last_attempt_at      TIMESTAMPTZ,
+				last_success_at      TIMESTAMPTZ,
+				last_http_status     INTEGER NOT NULL DEFAULT 0,
+				consecutive_failures INTEGER NOT NULL DEFAULT 0,
+				last_error           TEXT NOT NULL DEFAULT '',
+				last_error_code      TEXT NOT NULL DEFAULT '',
+				last_error_at        TIMESTAMPTZ,
+				paused               BOOLEAN NOT NULL DEFAULT FALSE,
+				paused_at            TIMESTAMPTZ,
+				retention            JSONB
+			)`,
+			`CREATE INDEX feeds_next_fetch_at ON feeds (next_fetch_at)`,
+
+			`CREATE TABLE scrape_runs (
+				id             TEXT PRIMARY KEY,
+				feed_id        TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				started_at     TIMESTAMPTZ NOT NULL,
+				finished_at    TIMESTAMPTZ NOT NULL,
+				duration_ms    BIGINT NOT NULL,
+				http_status    INTEGER NOT NULL DEFAULT 0,
+				not_modified   BOOLEAN NOT NULL DEFAULT FALSE,
+				bytes          BIGINT NOT NULL DEFAULT 0,
+				items_parsed   INTEGER NOT NULL DEFAULT 0,
+				items_new      INTEGER NOT NULL DEFAULT 0,
+				items_bad_date INTEGER NOT NULL DEFAULT 0,
+				items_updated  INTEGER NOT NULL DEFAULT 0,
+				error          TEXT NOT NULL DEFAULT '',
+				error_code     TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX scrape_runs_feed_started ON scrape_runs (feed_id, started_at DESC)`,
+
+			// search is weighted so title matches rank above description ones
+			`CREATE TABLE feed_items (
+				id           TEXT PRIMARY KEY,
+				feed_id      TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				guid         TEXT NOT NULL DEFAULT '',
+				identity_key TEXT NOT NULL,
+				title        TEXT NOT NULL,
+				link         TEXT NOT NULL,
+				description  TEXT NOT NULL,
+				pub_date     TIMESTAMPTZ NOT NULL,
+				author       TEXT NOT NULL DEFAULT '',
+				categories   JSONB,
+				enclosures   JSONB,
+				content_hash TEXT NOT NULL DEFAULT '',
+				revision     INTEGER NOT NULL DEFAULT 0,
+				updated_at   TIMESTAMPTZ,
+				starred      BOOLEAN NOT NULL DEFAULT FALSE,
+				search       TSVECTOR GENERATED ALWAYS AS (
+					setweight(to_tsvector('english', title), 'A') ||
+					setweight(to_tsvector('english', description), 'B')
+				) STORED,
+				UNIQUE (feed_id, identity_key)
+			)`,
+			`CREATE INDEX feed_items_feed_pub_date ON feed_items (feed_id, pub_date DESC)`,
+			`CREATE INDEX feed_items_search ON feed_items USING GIN (search)`,
+
+			`CREATE TABLE item_revisions (
+				id           TEXT PRIMARY KEY,
+				item_id      TEXT NOT NULL REFERENCES feed_items (id) ON DELETE CASCADE,
+				feed_id      TEXT NOT NULL,
+				revision     INTEGER NOT NULL,
+				title        TEXT NOT NULL,
+				link         TEXT NOT NULL,
+				description  TEXT NOT NULL,
+				author       TEXT NOT NULL DEFAULT '',
+				categories   JSONB,
+				enclosures   JSONB,
+				content_hash TEXT NOT NULL,
+				replaced_at  TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX item_revisions_item_revision ON item_revisions (item_id, revision DESC)`,
+
+			`CREATE TABLE feed_followers (
+				id         TEXT PRIMARY KEY,
+				feed_id    TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				user_id    TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL,
+				UNIQUE (feed_id, user_id)
+			)`,
+			`CREATE INDEX feed_followers_user ON feed_followers (user_id)`,
+
+			`CREATE TABLE scrape_jobs (
+				id          TEXT PRIMARY KEY,
+				feed_id     TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				status      TEXT NOT NULL,
+				created_at  TIMESTAMPTZ NOT NULL,
+				started_at  TIMESTAMPTZ,
+				finished_at TIMESTAMPTZ,
+				attempts    INTEGER NOT NULL DEFAULT 0,
+				new_items   INTEGER NOT NULL DEFAULT 0,
+				error       TEXT NOT NULL DEFAULT '',
+				error_code  TEXT NOT NULL DEFAULT '',
+				worker      TEXT NOT NULL DEFAULT '',
+				pending_key TEXT UNIQUE
+			)`,
+			`CREATE INDEX scrape_jobs_status_created ON scrape_jobs (status, created_at)`,
+			`CREATE INDEX scrape_jobs_finished ON scrape_jobs (finished_at)`,
+
+			`CREATE TABLE leases (
+				name        TEXT PRIMARY KEY,
+				holder      TEXT NOT NULL,
+				acquired_at TIMESTAMPTZ NOT NULL,
+				renewed_at  TIMESTAMPTZ NOT NULL,
+				expires_at  TIMESTAMPTZ NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "rekey feed_items whose link has a fragment",
+		Backfill:    rekeyFragmentLinks,
+	},
+	{
+		Version:     3,
+		Description: "keep feed_items when their feed is deleted, so starred items survive it",
+		Statements: []string{
+			`ALTER TABLE feed_items DROP CONSTRAINT IF EXISTS feed_items_feed_id_fkey`,
+		},
+	},
+}
+
+// rebindDollar numbers the ? placeholders of a query as $1, $2, ...,
+// leaving quoted literals alone
+func rebindDollar(query string) string {
+	var rebound strings.Builder
+	rebound.Grow(len(query) + 16)
+	n := 0
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			rebound.WriteByte('$')
+			rebound.WriteString(strconv.Itoa(n))
+			continue
+		}
+		rebound.WriteRune(r)
+	}
+	return rebound.String()
+}
+
+// searchPostgresItems ranks matches of the generated search column, which
+// plainto_tsquery requires to contain every word
+func searchPostgresItems(ctx context.Context, d *DB, words []string, feedID primitive.ObjectID, limit int64) ([]models.FeedItem, error) {
+	query := "SELECT " + itemColumns + ` FROM feed_items, plainto_tsquery('english', ?) AS terms
+		WHERE search @@ terms`
+	args := []interface{}{strings.Join(words, " ")}
+	if !feedID.IsZero() {
+		query += " AND feed_id = ?"
+		args = append(args, feedID.Hex())
+	}
+	return findItems(ctx, d.conn(), query+" ORDER BY ts_rank(search, terms) DESC, pub_date DESC LIMIT ?", append(args, limit)...)
+}
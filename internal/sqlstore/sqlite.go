@@ -0,0 +1,317 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OpenSQLite opens, creating if needed, the SQLite database at path. Call
+// Migrate before using its stores.
+//
+// Item search needs SQLite's FTS5 extension, which this driver only
+// compiles in when built with -tags sqlite_fts5. Without it OpenSQLite
+// fails, before anything is written to the database.
+func OpenSQLite(path string) (*DB, error) {
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_journal_mode", "WAL")
+	// Take the write lock when a transaction begins, so two transactions
+	// that read before writing cannot deadlock
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	var fts5 bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if !fts5 {
+		db.Close()
+		return nil, errors.New("the SQLite driver was built without FTS5, which item search needs; build with -tags sqlite_fts5 or make build")
+	}
+	return &DB{db: db, dialect: sqliteDialect}, nil
+}
+
+var sqliteDialect = &dialect{
+	migrations: sqliteMigrations,
+	rebind:     func(query string) string { return query },
+	uniqueViolation: func(err error) bool {
+		var sqliteErr sqlite3.Error
+		return errors.As(err, &sqliteErr) &&
+			(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+	},
+	foreignKeyViolation: func(err error) bool {
+		var sqliteErr sqlite3.Error
+		return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+	},
+	prepareSearch: prepareSQLiteSearch,
+	searchItems:   searchSQLiteItems,
+}
+
+// sqliteMigrations lists the SQLite schema in version order. New
+// migrations are appended with the next version; released ones are never
+// edited.
+var sqliteMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create users, feeds, scrape_runs, feed_items, item_revisions, feed_followers and scrape_jobs",
+		Statements: []string{
+			`CREATE TABLE users (
+				id           TEXT PRIMARY KEY,
+				firebase_uid TEXT NOT NULL,
+				username     TEXT NOT NULL,
+				email        TEXT NOT NULL,
+				created_at   TIMESTAMP NOT NULL,
+				updated_at   TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX users_firebase_uid ON users (firebase_uid)`,
+
+			`CREATE TABLE feeds (
+				id                   TEXT PRIMARY KEY,
+				name                 TEXT NOT NULL,
+				url                  TEXT NOT NULL,
+				user_id              TEXT NOT NULL,
+				created_at           TIMESTAMP NOT NULL,
+				updated_at           TIMESTAMP NOT NULL,
+				poll_interval        INTEGER NOT NULL DEFAULT 0,
+				current_interval     INTEGER NOT NULL DEFAULT 0,
+				next_fetch_at        TIMESTAMP,
+				hints                TEXT,
+				etag                 TEXT NOT NULL DEFAULT '',
+				last_modified        TEXT NOT NULL DEFAULT '',
+				last_attempt_at      TIMESTAMP,
+				last_success_at      TIMESTAMP,
+				last_http_status     INTEGER NOT NULL DEFAULT 0,
+				consecutive_failures INTEGER NOT NULL DEFAULT 0,
+				last_error           TEXT NOT NULL DEFAULT '',
+				last_error_code      TEXT NOT NULL DEFAULT '',
+				last_error_at        TIMESTAMP,
+				paused               BOOLEAN NOT NULL DEFAULT FALSE,
+				paused_at            TIMESTAMP,
+				retention            TEXT
+			)`,
+			`CREATE INDEX feeds_next_fetch_at ON feeds (next_fetch_at)`,
+
+			`CREATE TABLE scrape_runs (
+				id             TEXT PRIMARY KEY,
+				feed_id        TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				started_at     TIMESTAMP NOT NULL,
+				finished_at    TIMESTAMP NOT NULL,
+				duration_ms    INTEGER NOT NULL,
+				http_status    INTEGER NOT NULL DEFAULT 0,
+				not_modified   BOOLEAN NOT NULL DEFAULT FALSE,
+				bytes          INTEGER NOT NULL DEFAULT 0,
+				items_parsed   INTEGER NOT NULL DEFAULT 0,
+				items_new      INTEGER NOT NULL DEFAULT 0,
+				items_bad_date INTEGER NOT NULL DEFAULT 0,
+				items_updated  INTEGER NOT NULL DEFAULT 0,
+				error          TEXT NOT NULL DEFAULT '',
+				error_code     TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX scrape_runs_feed_started ON scrape_runs (feed_id, started_at DESC)`,
+
+			// seq gives items a stable rowid for the full-text index
+			`CREATE TABLE feed_items (
+				seq          INTEGER PRIMARY KEY,
+				id           TEXT NOT NULL UNIQUE,
+				feed_id      TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				guid         TEXT NOT NULL DEFAULT '',
+				identity_key TEXT NOT NULL,
+				title        TEXT NOT NULL,
+				link         TEXT NOT NULL,
+				description  TEXT NOT NULL,
+				pub_date     TIMESTAMP NOT NULL,
+				author       TEXT NOT NULL DEFAULT '',
+				categories   TEXT,
+				enclosures   TEXT,
+				content_hash TEXT NOT NULL DEFAULT '',
+				revision     INTEGER NOT NULL DEFAULT 0,
+				updated_at   TIMESTAMP,
+				starred      BOOLEAN NOT NULL DEFAULT FALSE,
+				UNIQUE (feed_id, identity_key)
+			)`,
+			`CREATE INDEX feed_items_feed_pub_date ON feed_items (feed_id, pub_date DESC)`,
+
+			`CREATE TABLE item_revisions (
+				id           TEXT PRIMARY KEY,
+				item_id      TEXT NOT NULL REFERENCES feed_items (id) ON DELETE CASCADE,
+				feed_id      TEXT NOT NULL,
+				revision     INTEGER NOT NULL,
+				title        TEXT NOT NULL,
+				link         TEXT NOT NULL,
+				description  TEXT NOT NULL,
+				author       TEXT NOT NULL DEFAULT '',
+				categories   TEXT,
+				enclosures   TEXT,
+				content_hash TEXT NOT NULL,
+				replaced_at  TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX item_revisions_item_revision ON item_revisions (item_id, revision DESC)`,
+
+			`CREATE TABLE feed_followers (
+				id         TEXT PRIMARY KEY,
+				feed_id    TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				user_id    TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				UNIQUE (feed_id, user_id)
+			)`,
+			`CREATE INDEX feed_followers_user ON feed_followers (user_id)`,
+
+			`CREATE TABLE scrape_jobs (
+				id          TEXT PRIMARY KEY,
+				feed_id     TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				status      TEXT NOT NULL,
+				created_at  TIMESTAMP NOT NULL,
+				started_at  TIMESTAMP,
+				finished_at TIMESTAMP,
+				attempts    INTEGER NOT NULL DEFAULT 0,
+				new_items   INTEGER NOT NULL DEFAULT 0,
+				error       TEXT NOT NULL DEFAULT '',
+				error_code  TEXT NOT NULL DEFAULT '',
+				worker      TEXT NOT NULL DEFAULT '',
+				pending_key TEXT UNIQUE
+			)`,
+			`CREATE INDEX scrape_jobs_status_created ON scrape_jobs (status, created_at)`,
+			`CREATE INDEX scrape_jobs_finished ON scrape_jobs (finished_at)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "rekey feed_items whose link has a fragment",
+		Backfill:    rekeyFragmentLinks,
+	},
+	{
+		// SQLite cannot drop a foreign key, so both tables are rebuilt. The
+		// new revisions table references the new items table, whose rename
+		// carries the reference along, so dropping the old tables cascades
+		// nowhere. The search triggers go with the old table and are put
+		// back by prepareSQLiteSearch.
+		Version:     3,
+		Description: "keep feed_items when their feed is deleted, so starred items survive it",
+		Statements: []string{
+			`CREATE TABLE feed_items_new (
+				seq          INTEGER PRIMARY KEY,
+				id           TEXT NOT NULL UNIQUE,
+				feed_id      TEXT NOT NULL,
+				guid         TEXT NOT NULL DEFAULT '',
+				identity_key TEXT NOT NULL,
+				title        TEXT NOT NULL,
+				link         TEXT NOT NULL,
+				description  TEXT NOT NULL,
+				pub_date     TIMESTAMP NOT NULL,
+				author       TEXT NOT NULL DEFAULT '',
+				categories   TEXT,
+				enclosures   TEXT,
+				content_hash TEXT NOT NULL DEFAULT '',
+				revision     INTEGER NOT NULL DEFAULT 0,
+				updated_at   TIMESTAMP,
+				starred      BOOLEAN NOT NULL DEFAULT FALSE,
+				UNIQUE (feed_id, identity_key)
+			)`,
+			`INSERT INTO feed_items_new SELECT seq, id, feed_id, guid, identity_key, title, link, description, pub_date,
+				author, categories, enclosures, content_hash, revision, updated_at, starred FROM feed_items`,
+			`CREATE TABLE item_revisions_new (
+				id           TEXT PRIMARY KEY,
+				item_id      TEXT NOT NULL REFERENCES feed_items_new (id) ON DELETE CASCADE,
+				feed_id      TEXT NOT NULL,
+				revision     INTEGER NOT NULL,
+				title        TEXT NOT NULL,
+				link         TEXT NOT NULL,
+				description  TEXT NOT NULL,
+				author       TEXT NOT NULL DEFAULT '',
+				categories   TEXT,
+				enclosures   TEXT,
+				content_hash TEXT NOT NULL,
+				replaced_at  TIMESTAMP NOT NULL
+			)`,
+			`INSERT INTO item_revisions_new SELECT id, item_id, feed_id, revision, title, link, description,
+				author, categories, enclosures, content_hash, replaced_at FROM item_revisions`,
+			`DROP TABLE item_revisions`,
+			`DROP TABLE feed_items`,
+			`ALTER TABLE feed_items_new RENAME TO feed_items`,
+			`ALTER TABLE item_revisions_new RENAME TO item_revisions`,
+			`CREATE INDEX feed_items_feed_pub_date ON feed_items (feed_id, pub_date DESC)`,
+			`CREATE INDEX item_revisions_item_revision ON item_revisions (item_id, revision DESC)`,
+		},
+	},
+}
+
+// sqliteSearchTriggers keep the external-content feed_items_fts index in
+// step with feed_items
+var sqliteSearchTriggers = map[string]string{
+	"feed_items_fts_insert": `CREATE TRIGGER IF NOT EXISTS feed_items_fts_insert AFTER INSERT ON feed_items BEGIN
+		INSERT INTO feed_items_fts (rowid, title, description) VALUES (new.seq, new.title, new.description);
+	END`,
+	"feed_items_fts_delete": `CREATE TRIGGER IF NOT EXISTS feed_items_fts_delete AFTER DELETE ON feed_items BEGIN
+		INSERT INTO feed_items_fts (feed_items_fts, rowid, title, description) VALUES ('delete', old.seq, old.title, old.description);
+	END`,
+	"feed_items_fts_update": `CREATE TRIGGER IF NOT EXISTS feed_items_fts_update AFTER UPDATE OF title, description ON feed_items BEGIN
+		INSERT INTO feed_items_fts (feed_items_fts, rowid, title, description) VALUES ('delete', old.seq, old.title, old.description);
+		INSERT INTO feed_items_fts (rowid, title, description) VALUES (new.seq, new.title, new.description);
+	END`,
+}
+
+// prepareSQLiteSearch creates the FTS5 index, rebuilding it from
+// feed_items if its triggers were missing
+func prepareSQLiteSearch(ctx context.Context, d *DB) error {
+	return d.inTx(ctx, func(tx conn) error {
+		var existing int
+		err := tx.queryRow(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'feed_items_fts_%'").Scan(&existing)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.exec(ctx, `CREATE VIRTUAL TABLE IF NOT EXISTS feed_items_fts USING fts5 (
+			title, description, content = 'feed_items', content_rowid = 'seq'
+		)`)
+		if err != nil {
+			return err
+		}
+		if existing == len(sqliteSearchTriggers) {
+			return nil
+		}
+		for _, statement := range sqliteSearchTriggers {
+			if _, err := tx.exec(ctx, statement); err != nil {
+				return err
+			}
+		}
+		_, err = tx.exec(ctx, "INSERT INTO feed_items_fts (feed_items_fts) VALUES ('rebuild')")
+		return err
+	})
+}
+
+// searchSQLiteItems ranks FTS5 matches with BM25, weighting titles above
+// descriptions
+func searchSQLiteItems(ctx context.Context, d *DB, words []string, feedID primitive.ObjectID, limit int64) ([]models.FeedItem, error) {
+	// Quoted, each word is matched literally and all are required
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	query := "SELECT " + itemColumns + ` FROM feed_items
+		JOIN (
+			SELECT rowid AS match_seq, bm25(feed_items_fts, 3.0, 1.0) AS score
+			FROM feed_items_fts WHERE feed_items_fts MATCH ?
+		) ON seq = match_seq`
+	args := []interface{}{strings.Join(terms, " ")}
+	if !feedID.IsZero() {
+		query += " WHERE feed_id = ?"
+		args = append(args, feedID.Hex())
+	}
+	return findItems(ctx, d.conn(), query+" ORDER BY score LIMIT ?", append(args, limit)...)
+}
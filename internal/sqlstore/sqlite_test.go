@@ -0,0 +1,429 @@
+//go:build sqlite_fts5
+
+package sqlstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// openTestDB opens and migrates a SQLite database in a temporary directory
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createFeed(t *testing.T, stores store.Stores) models.Feed {
+	t.Helper()
+	feed := models.Feed{ID: primitive.NewObjectID(), Name: "Test", Url: "http://example.com/rss", UserID: primitive.NewObjectID(), CreatedAt: time.Now()}
+	if err := stores.Feeds.Create(context.Background(), feed); err != nil {
+		t.Fatal(err)
+	}
+	return feed
+}
+
+func newItem(feedID primitive.ObjectID, key, title, description string) models.FeedItem {
+	return models.FeedItem{
+		ID:          primitive.NewObjectID(),
+		FeedID:      feedID,
+		IdentityKey: key,
+		Title:       title,
+		Description: description,
+		PubDate:     time.Now(),
+	}
+}
+
+func titles(items []models.FeedItem) []string {
+	var list []string
+	for _, item := range items {
+		list = append(list, item.Title)
+	}
+	return list
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	stores := openTestDB(t).Stores()
+	ctx := context.Background()
+	feed, other := createFeed(t, stores), createFeed(t, stores)
+	_, err := stores.Items.InsertNew(ctx, []models.FeedItem{
+		newItem(feed.ID, "guid:1", "Weekly notes", "Mentions golang in passing"),
+		newItem(feed.ID, "guid:2", "Golang release", "What is new"),
+		newItem(feed.ID, "guid:3", "Gardening", "Nothing to see"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Items.InsertNew(ctx, []models.FeedItem{newItem(other.ID, "guid:4", "Golang elsewhere", "")}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		feedID primitive.ObjectID
+		want   []string
+	}{
+		{"titles rank first", "golang", feed.ID, []string{"Golang release", "Weekly notes"}},
+		{"every word is required", "golang release", feed.ID, []string{"Golang release"}},
+		{"across feeds", "elsewhere", primitive.NilObjectID, []string{"Golang elsewhere"}},
+		{"other feeds are left out", "elsewhere", feed.ID, nil},
+		{"query syntax is matched literally", `golang" OR "gardening`, feed.ID, nil},
+		{"no words", "  ", feed.ID, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := stores.Items.Search(ctx, tt.query, tt.feedID, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := titles(found)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSQLiteSearchFollowsEditsAndDeletes(t *testing.T) {
+	stores := openTestDB(t).Stores()
+	ctx := context.Background()
+	feed := createFeed(t, stores)
+	item := newItem(feed.ID, "guid:1", "Draft title", "")
+	if _, err := stores.Items.InsertNew(ctx, []models.FeedItem{item}); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := item
+	edited.Title = "Final title"
+	edited.ContentHash = "edited"
+	revision := models.ItemRevision{ID: primitive.NewObjectID(), ItemID: item.ID, FeedID: feed.ID, Title: item.Title, ReplacedAt: time.Now()}
+	if applied, err := stores.Items.ApplyEdit(ctx, item, edited, revision); err != nil || !applied {
+		t.Fatalf("ApplyEdit = %v, %v", applied, err)
+	}
+	for query, want := range map[string]int{"draft": 0, "final": 1} {
+		if found, err := stores.Items.Search(ctx, query, feed.ID, 10); err != nil || len(found) != want {
+			t.Errorf("Search(%q) after an edit found %d items, %v; want %d", query, len(found), err, want)
+		}
+	}
+
+	if _, err := stores.Items.Delete(ctx, []primitive.ObjectID{item.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := stores.Items.Search(ctx, "final", feed.ID, 10); err != nil || len(found) != 0 {
+		t.Errorf("Search after a delete found %d items, %v", len(found), err)
+	}
+}
+
+func TestSQLiteInsertNew(t *testing.T) {
+	stores := openTestDB(t).Stores()
+	ctx := context.Background()
+	feed := createFeed(t, stores)
+
+	first := newItem(feed.ID, "guid:1", "First", "")
+	inserted, err := stores.Items.InsertNew(ctx, []models.FeedItem{first, newItem(feed.ID, "guid:1", "Duplicate in the batch", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(inserted); len(got) != 1 || got[0] != "First" {
+		t.Fatalf("inserted %q, want only the first of two items with one key", got)
+	}
+
+	// A later scrape only adds what is new, even for another feed's keys
+	other := createFeed(t, stores)
+	inserted, err = stores.Items.InsertNew(ctx, []models.FeedItem{
+		newItem(feed.ID, "guid:1", "First again", ""),
+		newItem(feed.ID, "guid:2", "Second", ""),
+		newItem(other.ID, "guid:1", "Other feed", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(inserted); len(got) != 2 || got[0] != "Second" || got[1] != "Other feed" {
+		t.Fatalf("inserted %q, want the second item and the other feed's", got)
+	}
+	if stored, err := stores.Items.Get(ctx, first.ID); err != nil || stored.Title != "First" {
+		t.Fatalf("stored item overwritten: %+v, %v", stored, err)
+	}
+}
+
+func TestSQLiteInsertNewMatchesLegacyLinkKeys(t *testing.T) {
+	stores := openTestDB(t).Stores()
+	ctx := context.Background()
+	feed := createFeed(t, stores)
+
+	// Stored by its link before the feed sent GUIDs
+	legacy := newItem(feed.ID, "link:http://example.com/a", "A", "")
+	legacy.Link = "http://example.com/a"
+	if _, err := stores.Items.InsertNew(ctx, []models.FeedItem{legacy}); err != nil {
+		t.Fatal(err)
+	}
+
+	withGUID := newItem(feed.ID, "guid:a", "A", "")
+	withGUID.GUID = "a"
+	withGUID.Link = "http://example.com/a?utm_source=rss"
+	unrelated := newItem(feed.ID, "guid:b", "B", "")
+	unrelated.GUID = "b"
+	unrelated.Link = "http://example.com/b"
+	inserted, err := stores.Items.InsertNew(ctx, []models.FeedItem{withGUID, unrelated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(inserted); len(got) != 1 || got[0] != "B" {
+		t.Fatalf("inserted %q, want only the item not stored under its link", got)
+	}
+
+	// An item that had a GUID all along does not hide a new one
+	sameLink := newItem(feed.ID, "guid:c", "C", "")
+	sameLink.GUID = "c"
+	sameLink.Link = "http://example.com/b"
+	if inserted, err := stores.Items.InsertNew(ctx, []models.FeedItem{sameLink}); err != nil || len(inserted) != 1 {
+		t.Fatalf("inserted %d items, %v; want the item sharing a link with one keyed by GUID", len(inserted), err)
+	}
+}
+
+func TestSQLiteUpdateUserMergesSetFields(t *testing.T) {
+	stores := openTestDB(t).Stores()
+	ctx := context.Background()
+	user := models.User{ID: primitive.NewObjectID(), FirebaseUID: "uid", Username: "old", Email: "old@example.com", CreatedAt: time.Now()}
+	if err := stores.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ID is omitted from a MongoDB $set when zero, and kept here too
+	if err := stores.Users.Update(ctx, user.ID, models.User{FirebaseUID: "uid", Username: "new", Email: "new@example.com", CreatedAt: user.CreatedAt}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := stores.Users.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ID != user.ID || stored.Username != "new" || stored.Email != "new@example.com" {
+		t.Fatalf("update not merged: %+v", stored)
+	}
+}
+
+func TestSQLiteUpdateFeed(t *testing.T) {
+	stores := openTestDB(t).Stores()
+	ctx := context.Background()
+	later := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	feed := models.Feed{
+		ID: primitive.NewObjectID(), Name: "Test", Url: "http://example.com/rss", UserID: primitive.NewObjectID(), CreatedAt: time.Now(),
+		PollInterval: 3600, NextFetchAt: &later, Retention: &models.RetentionPolicy{MaxItems: 5},
+	}
+	if err := stores.Feeds.Create(ctx, feed); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Feeds.RecordFetchSuccess(ctx, feed.ID, store.FetchSuccess{At: time.Now(), HTTPStatus: 200, ETag: `"v1"`}); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := store.FeedSettings{Name: "Renamed", Url: feed.Url, UserID: feed.UserID, UpdatedAt: time.Now()}
+	if err := stores.Feeds.Update(ctx, feed.ID, settings); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := stores.Feeds.Get(ctx, feed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Renamed" || stored.PollInterval != 0 || stored.Retention != nil {
+		t.Fatalf("settings not replaced: %+v", stored)
+	}
+	if stored.ETag != `"v1"` || stored.NextFetchAt == nil || !stored.NextFetchAt.Equal(later) {
+		t.Fatalf("fetch state changed by a settings update: %+v", stored)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	settings.Url = "http://example.org/feed"
+	settings.NextFetchAt = &now
+	settings.ResetFetchState = true
+	if err := stores.Feeds.Update(ctx, feed.ID, settings); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = stores.Feeds.Get(ctx, feed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ETag != "" || stored.LastSuccessAt != nil || stored.LastHTTPStatus != 0 {
+		t.Fatalf("fetch state not reset: %+v", stored)
+	}
+	if stored.NextFetchAt == nil || !stored.NextFetchAt.Equal(now) {
+		t.Fatalf("feed not rescheduled: %v", stored.NextFetchAt)
+	}
+}
+
+func TestSQLiteDeleteFeedKeepsStarredItems(t *testing.T) {
+	stores := openTestDB(t).Stores()
+	ctx := context.Background()
+	feed := createFeed(t, stores)
+	kept, deleted := newItem(feed.ID, "guid:1", "Kept", ""), newItem(feed.ID, "guid:2", "Deleted", "")
+	if _, err := stores.Items.InsertNew(ctx, []models.FeedItem{kept, deleted}); err != nil {
+		t.Fatal(err)
+	}
+	edited := kept
+	edited.Title = "Kept, edited"
+	revision := models.ItemRevision{ID: primitive.NewObjectID(), ItemID: kept.ID, FeedID: feed.ID, Title: kept.Title, ReplacedAt: time.Now()}
+	if _, err := stores.Items.ApplyEdit(ctx, kept, edited, revision); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Items.SetStarred(ctx, kept.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stores.Items.DeleteByFeed(ctx, feed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Feeds.Delete(ctx, feed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Items.Get(ctx, kept.ID); err != nil {
+		t.Errorf("starred item deleted with its feed: %v", err)
+	}
+	if revisions, err := stores.Items.Revisions(ctx, kept.ID); err != nil || len(revisions) != 1 {
+		t.Errorf("starred item has %d revisions, %v; want 1", len(revisions), err)
+	}
+	if _, err := stores.Items.Get(ctx, deleted.ID); err != store.ErrNotFound {
+		t.Errorf("unstarred item kept: %v", err)
+	}
+}
+
+// TestSQLiteMigrationsKeepData applies the later migrations to a database
+// holding data in the first schema
+func TestSQLiteMigrationsKeepData(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.dialect = &dialect{}
+	*db.dialect = *sqliteDialect
+	db.dialect.migrations = sqliteMigrations[:1]
+	if _, err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := db.Stores()
+	feed := createFeed(t, stores)
+	anchored := newItem(feed.ID, "link:http://example.com/changelog", "v1", "")
+	anchored.Link = "http://example.com/changelog#v1"
+	starred := newItem(feed.ID, "guid:1", "Starred", "searchable")
+	if _, err := stores.Items.InsertNew(ctx, []models.FeedItem{anchored, starred}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Items.SetStarred(ctx, starred.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	db.dialect.migrations = sqliteMigrations
+	if _, err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := stores.Items.Get(ctx, anchored.ID); err != nil || stored.IdentityKey != "link:http://example.com/changelog#v1" {
+		t.Errorf("item linking to an anchor keyed %q, %v", stored.IdentityKey, err)
+	}
+	if found, err := stores.Items.Search(ctx, "searchable", feed.ID, 10); err != nil || len(found) != 1 {
+		t.Errorf("search after the migrations found %d items, %v", len(found), err)
+	}
+	if err := stores.Feeds.Delete(ctx, feed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Items.Get(ctx, starred.ID); err != nil {
+		t.Errorf("item deleted with its feed after the migrations: %v", err)
+	}
+}
+
+func TestSQLiteJobsCoalesce(t *testing.T) {
+	db := openTestDB(t)
+	jobStore := db.Jobs()
+	ctx := context.Background()
+	feedID, otherID := createFeed(t, db.Stores()).ID, createFeed(t, db.Stores()).ID
+
+	enqueue := func(feedID primitive.ObjectID) (*models.ScrapeJob, bool) {
+		t.Helper()
+		job, coalesced, err := jobStore.Enqueue(ctx, feedID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return job, coalesced
+	}
+
+	first, coalesced := enqueue(feedID)
+	if coalesced || first.Status != models.JobQueued {
+		t.Fatalf("first job coalesced %v with status %s", coalesced, first.Status)
+	}
+	if again, coalesced := enqueue(feedID); !coalesced || again.ID != first.ID {
+		t.Fatalf("queued job not reused: coalesced %v, %s != %s", coalesced, again.ID.Hex(), first.ID.Hex())
+	}
+	if other, coalesced := enqueue(otherID); coalesced || other.ID == first.ID {
+		t.Fatal("another feed's job coalesced")
+	}
+
+	claimed, err := jobStore.Claim(ctx, "worker", time.Hour)
+	if err != nil || claimed == nil || claimed.ID != first.ID || claimed.Status != models.JobRunning {
+		t.Fatalf("Claim = %+v, %v; want the first job running", claimed, err)
+	}
+	if again, coalesced := enqueue(feedID); !coalesced || again.ID != first.ID {
+		t.Fatal("running job not reused")
+	}
+
+	if err := jobStore.Complete(ctx, claimed, 3, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	done, err := jobStore.Get(ctx, first.ID)
+	if err != nil || done.Status != models.JobSucceeded || done.NewItems != 3 {
+		t.Fatalf("completed job %+v, %v", done, err)
+	}
+	if next, coalesced := enqueue(feedID); coalesced || next.ID == first.ID {
+		t.Fatal("finished job reused")
+	}
+}
+
+func TestSQLiteJobsReclaimStale(t *testing.T) {
+	db := openTestDB(t)
+	jobStore := db.Jobs()
+	ctx := context.Background()
+	if _, _, err := jobStore.Enqueue(ctx, createFeed(t, db.Stores()).ID); err != nil {
+		t.Fatal(err)
+	}
+
+	crashed, err := jobStore.Claim(ctx, "crashed", time.Hour)
+	if err != nil || crashed == nil {
+		t.Fatalf("Claim = %v, %v", crashed, err)
+	}
+	if job, err := jobStore.Claim(ctx, "other", time.Hour); err != nil || job != nil {
+		t.Fatalf("running job claimed twice: %+v, %v", job, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	reclaimed, err := jobStore.Claim(ctx, "other", time.Millisecond)
+	if err != nil || reclaimed == nil || reclaimed.ID != crashed.ID || reclaimed.Attempts != 2 {
+		t.Fatalf("stale job not reclaimed: %+v, %v", reclaimed, err)
+	}
+
+	// The crashed worker no longer owns the job, so its late result is dropped
+	if err := jobStore.Complete(ctx, crashed, 1, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := jobStore.Get(ctx, crashed.ID); err != nil || job.Status != models.JobRunning || job.Worker != "other" {
+		t.Fatalf("job after a late completion: %+v, %v", job, err)
+	}
+}
@@ -0,0 +1,202 @@
+// Package sqlstore implements the store interfaces and the scrape job store
+// on a SQL database, for deployments that would rather not run MongoDB.
+//
+// Queries are written once with ? placeholders; each dialect rewrites them
+// for its driver and supplies its own schema and item search.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/jobs"
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DB is an open SQL database holding the stores' tables
+type DB struct {
+	db      *sql.DB
+	dialect *dialect
+}
+
+// dialect is what differs between the supported databases
+type dialect struct {
+	migrations []Migration
+	// rebind rewrites a query's ? placeholders into the driver's own
+	rebind func(query string) string
+	// migrationLock, if set, runs first in every migration transaction to
+	// keep instances booting together from migrating at the same time
+	migrationLock string
+	// uniqueViolation and foreignKeyViolation classify constraint errors
+	uniqueViolation     func(err error) bool
+	foreignKeyViolation func(err error) bool
+	// prepareSearch runs after the migrations and makes sure the
+	// full-text index is in place
+	prepareSearch func(ctx context.Context, db *DB) error
+	// searchItems finds items matching every word with the full-text index
+	searchItems func(ctx context.Context, db *DB, words []string, feedID primitive.ObjectID, limit int64) ([]models.FeedItem, error)
+}
+
+// Stores returns the SQL implementations of the stores
+func (d *DB) Stores() store.Stores {
+	return store.Stores{
+		Users:     &UserStore{db: d},
+		Feeds:     &FeedStore{db: d},
+		Items:     &ItemStore{db: d},
+		Followers: &FollowerStore{db: d},
+	}
+}
+
+// Jobs returns the SQL implementation of the scrape job store
+func (d *DB) Jobs() *JobStore {
+	return &JobStore{db: d}
+}
+
+// Close closes the database
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// querier is what conn needs from a *sql.DB or *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn runs queries on the database or a transaction, rewriting their
+// placeholders for the dialect
+type conn struct {
+	q      querier
+	rebind func(query string) string
+}
+
+func (c conn) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.q.ExecContext(ctx, c.rebind(query), args...)
+}
+
+func (c conn) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.q.QueryContext(ctx, c.rebind(query), args...)
+}
+
+func (c conn) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.q.QueryRowContext(ctx, c.rebind(query), args...)
+}
+
+// conn returns a conn outside any transaction
+func (d *DB) conn() conn {
+	return conn{q: d.db, rebind: d.dialect.rebind}
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds
+func (d *DB) inTx(ctx context.Context, fn func(tx conn) error) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(conn{q: tx, rebind: d.dialect.rebind}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// notFound translates the driver's missing-row error into the store's
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+// placeholders returns n comma-separated placeholders for an IN list
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// idArgs converts IDs into query arguments
+func idArgs(ids []primitive.ObjectID) []interface{} {
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id.Hex())
+	}
+	return args
+}
+
+// parseID converts a stored ID back, leaving it zero if it is not hex
+func parseID(hex string) primitive.ObjectID {
+	id, _ := primitive.ObjectIDFromHex(hex)
+	return id
+}
+
+// utc normalizes a time before it is stored, so stored times compare
+// correctly even where the database keeps them as text
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+// nullTime stores a nil time as NULL
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// encodeJSON stores slices and nested structs as JSON text, with nil and
+// empty ones stored as NULL
+func encodeJSON(v interface{}) (interface{}, error) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil, nil
+		}
+	case reflect.Slice:
+		if value.Len() == 0 {
+			return nil, nil
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// decodeJSON reads a column written by encodeJSON into v, leaving v alone
+// if the column is NULL
+func decodeJSON(column sql.NullString, v interface{}) error {
+	if !column.Valid || column.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(column.String), v)
+}
+
+// The SQL stores implement the store interfaces
+var (
+	_ store.UserStore     = (*UserStore)(nil)
+	_ store.FeedStore     = (*FeedStore)(nil)
+	_ store.ItemStore     = (*ItemStore)(nil)
+	_ store.FollowerStore = (*FollowerStore)(nil)
+	_ jobs.Store          = (*JobStore)(nil)
+)
@@ -0,0 +1,71 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserStore is the SQL store.UserStore, backed by "users"
+type UserStore struct {
+	db *DB
+}
+
+const userColumns = "id, firebase_uid, username, email, created_at, updated_at"
+
+func (s *UserStore) Create(ctx context.Context, user models.User) error {
+	return s.write(ctx, s.db.conn(), "INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?)", user)
+}
+
+func (s *UserStore) Get(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	return s.get(ctx, s.db.conn(), "id", id.Hex())
+}
+
+func (s *UserStore) GetByFirebaseUID(ctx context.Context, uid string) (models.User, error) {
+	return s.get(ctx, s.db.conn(), "firebase_uid", uid)
+}
+
+func (s *UserStore) Update(ctx context.Context, id primitive.ObjectID, user models.User) error {
+	return s.db.inTx(ctx, func(tx conn) error {
+		stored, err := s.get(ctx, tx, "id", id.Hex())
+		if err == store.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := store.MergeSet(&stored, user); err != nil {
+			return err
+		}
+		return s.write(ctx, tx, `UPDATE users SET
+			id = ?, firebase_uid = ?, username = ?, email = ?, created_at = ?, updated_at = ?
+			WHERE id = ?`, stored, id.Hex())
+	})
+}
+
+func (s *UserStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.db.conn().exec(ctx, "DELETE FROM users WHERE id = ?", id.Hex())
+	return err
+}
+
+func (s *UserStore) get(ctx context.Context, c conn, column string, value string) (models.User, error) {
+	var user models.User
+	var id string
+	err := c.queryRow(ctx, "SELECT "+userColumns+" FROM users WHERE "+column+" = ?", value).
+		Scan(&id, &user.FirebaseUID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	user.ID = parseID(id)
+	return user, notFound(err)
+}
+
+// write runs an INSERT or UPDATE taking the user's columns in order,
+// followed by extra arguments
+func (s *UserStore) write(ctx context.Context, c conn, query string, user models.User, extra ...interface{}) error {
+	args := []interface{}{user.ID.Hex(), user.FirebaseUID, user.Username, user.Email, utc(user.CreatedAt), utc(user.UpdatedAt)}
+	_, err := c.exec(ctx, query, append(args, extra...)...)
+	if err != nil && s.db.dialect.uniqueViolation(err) {
+		return store.ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,705 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"github.com/kwabena369/scrapper/internal/rss"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewMemoryStores returns empty in-memory stores. They keep nothing across
+// restarts and are meant for tests and local experiments.
+func NewMemoryStores() Stores {
+	return Stores{
+		Users:     NewMemoryUserStore(),
+		Feeds:     NewMemoryFeedStore(),
+		Items:     NewMemoryItemStore(),
+		Followers: NewMemoryFollowerStore(),
+	}
+}
+
+// MergeSet copies the fields of src that would be written by a MongoDB
+// $set of the same struct onto dst, so stores that rewrite whole records
+// leave the same fields untouched (those tagged omitempty and left zero)
+func MergeSet(dst, src interface{}) error {
+	var current, changes bson.M
+	if err := roundTrip(dst, &current); err != nil {
+		return err
+	}
+	if err := roundTrip(src, &changes); err != nil {
+		return err
+	}
+	for key, value := range changes {
+		current[key] = value
+	}
+	return roundTrip(current, dst)
+}
+
+// roundTrip copies from into to through their BSON encoding
+func roundTrip(from, to interface{}) error {
+	data, err := bson.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, to)
+}
+
+// MemoryUserStore is an in-memory UserStore
+type MemoryUserStore struct {
+	mu    sync.Mutex
+	users map[primitive.ObjectID]models.User
+}
+
+// NewMemoryUserStore returns an empty MemoryUserStore
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[primitive.ObjectID]models.User)}
+}
+
+func (s *MemoryUserStore) Create(ctx context.Context, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.ID]; ok {
+		return ErrDuplicate
+	}
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *MemoryUserStore) Get(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *MemoryUserStore) GetByFirebaseUID(ctx context.Context, uid string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.FirebaseUID == uid {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (s *MemoryUserStore) Update(ctx context.Context, id primitive.ObjectID, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.users[id]
+	if !ok {
+		return nil
+	}
+	if err := MergeSet(&stored, user); err != nil {
+		return err
+	}
+	s.users[id] = stored
+	return nil
+}
+
+func (s *MemoryUserStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, id)
+	return nil
+}
+
+// MemoryFeedStore is an in-memory FeedStore
+type MemoryFeedStore struct {
+	mu    sync.Mutex
+	feeds map[primitive.ObjectID]models.Feed
+	runs  []models.ScrapeRun
+}
+
+// NewMemoryFeedStore returns an empty MemoryFeedStore
+func NewMemoryFeedStore() *MemoryFeedStore {
+	return &MemoryFeedStore{feeds: make(map[primitive.ObjectID]models.Feed)}
+}
+
+func (s *MemoryFeedStore) Create(ctx context.Context, feed models.Feed) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.feeds[feed.ID]; ok {
+		return ErrDuplicate
+	}
+	s.feeds[feed.ID] = feed
+	return nil
+}
+
+func (s *MemoryFeedStore) Get(ctx context.Context, id primitive.ObjectID) (models.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feeds[id]
+	if !ok {
+		return models.Feed{}, ErrNotFound
+	}
+	return feed, nil
+}
+
+func (s *MemoryFeedStore) Update(ctx context.Context, id primitive.ObjectID, settings FeedSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feeds[id]
+	if !ok {
+		return nil
+	}
+	feed.Name = settings.Name
+	feed.Url = settings.Url
+	feed.UserID = settings.UserID
+	feed.PollInterval = settings.PollInterval
+	feed.Retention = settings.Retention
+	feed.UpdatedAt = settings.UpdatedAt
+	if settings.NextFetchAt != nil {
+		at := *settings.NextFetchAt
+		feed.NextFetchAt = &at
+	}
+	if settings.ResetFetchState {
+		feed.Hints = nil
+		feed.ETag = ""
+		feed.LastModified = ""
+		feed.LastAttemptAt = nil
+		feed.LastSuccessAt = nil
+		feed.LastHTTPStatus = 0
+		feed.ConsecutiveFailures = 0
+		feed.LastError = ""
+		feed.LastErrorCode = ""
+		feed.LastErrorAt = nil
+		feed.Paused = false
+		feed.PausedAt = nil
+	}
+	s.feeds[id] = feed
+	return nil
+}
+
+func (s *MemoryFeedStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.feeds, id)
+	return nil
+}
+
+func (s *MemoryFeedStore) List(ctx context.Context, filter FeedFilter) ([]models.Feed, error) {
+	var match func(models.Feed) bool
+	switch filter.Health {
+	case HealthAny:
+		match = func(models.Feed) bool { return true }
+	case HealthHealthy:
+		match = func(feed models.Feed) bool { return feed.ConsecutiveFailures == 0 && !feed.Paused }
+	case HealthUnhealthy:
+		match = func(feed models.Feed) bool { return feed.ConsecutiveFailures > 0 || feed.Paused }
+	case HealthPaused:
+		match = func(feed models.Feed) bool { return feed.Paused }
+	default:
+		return nil, fmt.Errorf("unknown health filter %q", filter.Health)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var feeds []models.Feed
+	for _, feed := range s.feeds {
+		if match(feed) {
+			feeds = append(feeds, feed)
+		}
+	}
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].ID.Hex() < feeds[j].ID.Hex() })
+	return feeds, nil
+}
+
+func (s *MemoryFeedStore) RecordFetchSuccess(ctx context.Context, id primitive.ObjectID, success FetchSuccess) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feeds[id]
+	if !ok {
+		return nil
+	}
+	at := success.At
+	feed.ETag = success.ETag
+	feed.LastModified = success.LastModified
+	feed.LastAttemptAt = &at
+	feed.LastSuccessAt = &at
+	feed.LastHTTPStatus = success.HTTPStatus
+	if success.Hints != nil {
+		feed.Hints = success.Hints
+	}
+	feed.ConsecutiveFailures = 0
+	feed.LastError = ""
+	feed.LastErrorCode = ""
+	feed.LastErrorAt = nil
+	feed.Paused = false
+	feed.PausedAt = nil
+	s.feeds[id] = feed
+	return nil
+}
+
+func (s *MemoryFeedStore) RecordFetchFailure(ctx context.Context, id primitive.ObjectID, failure FetchFailure) (models.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feeds[id]
+	if !ok {
+		return models.Feed{}, ErrNotFound
+	}
+	at := failure.At
+	feed.LastAttemptAt = &at
+	feed.LastError = failure.Error
+	feed.LastErrorCode = failure.ErrorCode
+	feed.LastErrorAt = &at
+	feed.LastHTTPStatus = failure.HTTPStatus
+	feed.ConsecutiveFailures++
+	s.feeds[id] = feed
+	return feed, nil
+}
+
+func (s *MemoryFeedStore) Pause(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feeds[id]
+	if !ok {
+		return nil
+	}
+	feed.Paused = true
+	feed.PausedAt = &at
+	s.feeds[id] = feed
+	return nil
+}
+
+func (s *MemoryFeedStore) Due(ctx context.Context, now time.Time) ([]models.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var feeds []models.Feed
+	for _, feed := range s.feeds {
+		if !feed.Paused && (feed.NextFetchAt == nil || !feed.NextFetchAt.After(now)) {
+			feeds = append(feeds, feed)
+		}
+	}
+	// Never-fetched feeds first, then the longest overdue
+	sort.Slice(feeds, func(i, j int) bool {
+		a, b := feeds[i].NextFetchAt, feeds[j].NextFetchAt
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Before(*b)
+	})
+	return feeds, nil
+}
+
+func (s *MemoryFeedStore) NextDue(ctx context.Context) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next *time.Time
+	for _, feed := range s.feeds {
+		if feed.Paused || feed.NextFetchAt == nil {
+			continue
+		}
+		if next == nil || feed.NextFetchAt.Before(*next) {
+			at := *feed.NextFetchAt
+			next = &at
+		}
+	}
+	return next, nil
+}
+
+func (s *MemoryFeedStore) Reschedule(ctx context.Context, id primitive.ObjectID, interval int, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feeds[id]
+	if !ok {
+		return nil
+	}
+	feed.CurrentInterval = interval
+	feed.NextFetchAt = &next
+	s.feeds[id] = feed
+	return nil
+}
+
+func (s *MemoryFeedStore) RecordRun(ctx context.Context, run models.ScrapeRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs = append(s.runs, run)
+	return nil
+}
+
+func (s *MemoryFeedStore) ListRuns(ctx context.Context, feedID primitive.ObjectID, limit, offset int64) ([]models.ScrapeRun, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matching []models.ScrapeRun
+	for _, run := range s.runs {
+		if run.FeedID == feedID {
+			matching = append(matching, run)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool { return matching[i].StartedAt.After(matching[j].StartedAt) })
+
+	total := int64(len(matching))
+	runs := []models.ScrapeRun{}
+	for i := offset; i < total && i < offset+limit; i++ {
+		runs = append(runs, matching[i])
+	}
+	return runs, total, nil
+}
+
+// MemoryItemStore is an in-memory ItemStore
+type MemoryItemStore struct {
+	mu        sync.Mutex
+	items     map[primitive.ObjectID]models.FeedItem
+	revisions []models.ItemRevision
+}
+
+// NewMemoryItemStore returns an empty MemoryItemStore
+func NewMemoryItemStore() *MemoryItemStore {
+	return &MemoryItemStore{items: make(map[primitive.ObjectID]models.FeedItem)}
+}
+
+func (s *MemoryItemStore) InsertNew(ctx context.Context, items []models.FeedItem) ([]models.FeedItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var inserted []models.FeedItem
+	for _, item := range items {
+		if s.matchLocked(item) {
+			continue
+		}
+		s.items[item.ID] = item
+		inserted = append(inserted, item)
+	}
+	return inserted, nil
+}
+
+// matchLocked reports whether an item is already stored under its identity
+// key, or under its link before the feed sent GUIDs
+func (s *MemoryItemStore) matchLocked(item models.FeedItem) bool {
+	linkKey := ""
+	if item.Link != "" {
+		linkKey = "link:" + rss.NormalizeLink(item.Link)
+	}
+	for _, stored := range s.items {
+		if stored.FeedID != item.FeedID {
+			continue
+		}
+		if stored.IdentityKey == item.IdentityKey || (linkKey != "" && stored.IdentityKey == linkKey && stored.GUID == "") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryItemStore) FindByKeys(ctx context.Context, feedID primitive.ObjectID, keys []string) ([]models.FeedItem, error) {
+	wanted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		wanted[key] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []models.FeedItem
+	for _, item := range s.items {
+		if item.FeedID == feedID && wanted[item.IdentityKey] {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (s *MemoryItemStore) ApplyEdit(ctx context.Context, previous, updated models.FeedItem, revision models.ItemRevision) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.items[previous.ID]
+	if !ok || stored.ContentHash != previous.ContentHash {
+		return false, nil
+	}
+	stored.Title = updated.Title
+	stored.Link = updated.Link
+	stored.Description = updated.Description
+	stored.Author = updated.Author
+	stored.Categories = updated.Categories
+	stored.Enclosures = updated.Enclosures
+	stored.ContentHash = updated.ContentHash
+	stored.UpdatedAt = updated.UpdatedAt
+	stored.Revision++
+	s.items[stored.ID] = stored
+	s.revisions = append(s.revisions, revision)
+	return true, nil
+}
+
+func (s *MemoryItemStore) Get(ctx context.Context, id primitive.ObjectID) (models.FeedItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return models.FeedItem{}, ErrNotFound
+	}
+	return item, nil
+}
+
+func (s *MemoryItemStore) ListByFeed(ctx context.Context, feedID primitive.ObjectID) ([]models.FeedItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []models.FeedItem
+	for _, item := range s.items {
+		if item.FeedID == feedID {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID.Hex() < items[j].ID.Hex() })
+	return items, nil
+}
+
+func (s *MemoryItemStore) Revisions(ctx context.Context, itemID primitive.ObjectID) ([]models.ItemRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revisions := []models.ItemRevision{}
+	for _, revision := range s.revisions {
+		if revision.ItemID == itemID {
+			revisions = append(revisions, revision)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision > revisions[j].Revision })
+	return revisions, nil
+}
+
+func (s *MemoryItemStore) SetStarred(ctx context.Context, id primitive.ObjectID, starred bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return ErrNotFound
+	}
+	item.Starred = starred
+	s.items[id] = item
+	return nil
+}
+
+func (s *MemoryItemStore) Search(ctx context.Context, query string, feedID primitive.ObjectID, limit int64) ([]models.FeedItem, error) {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []models.FeedItem
+	for _, item := range s.items {
+		if !feedID.IsZero() && item.FeedID != feedID {
+			continue
+		}
+		text := strings.ToLower(item.Title + " " + item.Description)
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].PubDate.After(items[j].PubDate) })
+	if int64(len(items)) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (s *MemoryItemStore) PrunableByAge(ctx context.Context, feedID primitive.ObjectID, cutoff time.Time) ([]primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []primitive.ObjectID
+	for _, item := range s.items {
+		if item.FeedID == feedID && !item.Starred && item.PubDate.Before(cutoff) {
+			ids = append(ids, item.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryItemStore) PrunableByCount(ctx context.Context, feedID primitive.ObjectID, keep int) ([]primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []models.FeedItem
+	for _, item := range s.items {
+		if item.FeedID == feedID && !item.Starred {
+			items = append(items, item)
+		}
+	}
+	if len(items) <= keep {
+		return nil, nil
+	}
+	// Newest first, as the MongoDB store sorts them
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].PubDate.Equal(items[j].PubDate) {
+			return items[i].PubDate.After(items[j].PubDate)
+		}
+		return items[i].ID.Hex() > items[j].ID.Hex()
+	})
+	var ids []primitive.ObjectID
+	for _, item := range items[keep:] {
+		ids = append(ids, item.ID)
+	}
+	return ids, nil
+}
+
+func (s *MemoryItemStore) FeedIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[primitive.ObjectID]bool)
+	var ids []primitive.ObjectID
+	for _, item := range s.items {
+		if !seen[item.FeedID] {
+			seen[item.FeedID] = true
+			ids = append(ids, item.FeedID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryItemStore) CountByFeeds(ctx context.Context, feedIDs []primitive.ObjectID) (int64, error) {
+	wanted := make(map[primitive.ObjectID]bool, len(feedIDs))
+	for _, id := range feedIDs {
+		wanted[id] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, item := range s.items {
+		if wanted[item.FeedID] && !item.Starred {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryItemStore) Delete(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	for _, id := range ids {
+		if item, ok := s.items[id]; ok && !item.Starred {
+			delete(s.items, id)
+			s.deleteRevisionsLocked(func(revision models.ItemRevision) bool { return revision.ItemID == id })
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *MemoryItemStore) DeleteByFeed(ctx context.Context, feedID primitive.ObjectID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	for id, item := range s.items {
+		if item.FeedID == feedID && !item.Starred {
+			delete(s.items, id)
+			deleted++
+		}
+	}
+	s.deleteRevisionsLocked(func(revision models.ItemRevision) bool {
+		_, kept := s.items[revision.ItemID]
+		return revision.FeedID == feedID && !kept
+	})
+	return deleted, nil
+}
+
+// deleteRevisionsLocked drops the revisions for which remove returns true
+func (s *MemoryItemStore) deleteRevisionsLocked(remove func(models.ItemRevision) bool) {
+	kept := s.revisions[:0]
+	for _, revision := range s.revisions {
+		if !remove(revision) {
+			kept = append(kept, revision)
+		}
+	}
+	s.revisions = kept
+}
+
+// MemoryFollowerStore is an in-memory FollowerStore
+type MemoryFollowerStore struct {
+	mu        sync.Mutex
+	followers []models.FeedFollower
+}
+
+// NewMemoryFollowerStore returns an empty MemoryFollowerStore
+func NewMemoryFollowerStore() *MemoryFollowerStore {
+	return &MemoryFollowerStore{}
+}
+
+func (s *MemoryFollowerStore) Follow(ctx context.Context, follower models.FeedFollower) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.followers {
+		if existing.FeedID == follower.FeedID && existing.UserID == follower.UserID {
+			return ErrDuplicate
+		}
+	}
+	s.followers = append(s.followers, follower)
+	return nil
+}
+
+func (s *MemoryFollowerStore) Unfollow(ctx context.Context, feedID primitive.ObjectID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, follower := range s.followers {
+		if follower.FeedID == feedID && follower.UserID == userID {
+			s.followers = append(s.followers[:i], s.followers[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryFollowerStore) ListByUser(ctx context.Context, userID string) ([]models.FeedFollower, error) {
+	return s.filter(func(follower models.FeedFollower) bool { return follower.UserID == userID }), nil
+}
+
+func (s *MemoryFollowerStore) ListByFeed(ctx context.Context, feedID primitive.ObjectID) ([]models.FeedFollower, error) {
+	return s.filter(func(follower models.FeedFollower) bool { return follower.FeedID == feedID }), nil
+}
+
+func (s *MemoryFollowerStore) DeleteByUser(ctx context.Context, userID string) error {
+	s.remove(func(follower models.FeedFollower) bool { return follower.UserID == userID })
+	return nil
+}
+
+func (s *MemoryFollowerStore) DeleteByFeed(ctx context.Context, feedID primitive.ObjectID) error {
+	s.remove(func(follower models.FeedFollower) bool { return follower.FeedID == feedID })
+	return nil
+}
+
+// filter returns the followers for which match returns true
+func (s *MemoryFollowerStore) filter(match func(models.FeedFollower) bool) []models.FeedFollower {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var followers []models.FeedFollower
+	for _, follower := range s.followers {
+		if match(follower) {
+			followers = append(followers, follower)
+		}
+	}
+	return followers
+}
+
+// remove drops the followers for which match returns true
+func (s *MemoryFollowerStore) remove(match func(models.FeedFollower) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.followers[:0]
+	for _, follower := range s.followers {
+		if !match(follower) {
+			kept = append(kept, follower)
+		}
+	}
+	s.followers = kept
+}
+
+// The in-memory stores implement the store interfaces
+var (
+	_ UserStore     = (*MemoryUserStore)(nil)
+	_ FeedStore     = (*MemoryFeedStore)(nil)
+	_ ItemStore     = (*MemoryItemStore)(nil)
+	_ FollowerStore = (*MemoryFollowerStore)(nil)
+)
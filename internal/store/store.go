@@ -0,0 +1,173 @@
+// Package store defines the storage interfaces the handlers, scheduler and
+// janitor work against, so they do not depend on a particular database.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kwabena369/scrapper/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when a record would duplicate an existing one
+	ErrDuplicate = errors.New("already exists")
+)
+
+// ScrapeRunRetention is how long scrape run history is kept
+const ScrapeRunRetention = 30 * 24 * time.Hour
+
+// Stores bundles one implementation of each store
+type Stores struct {
+	Users     UserStore
+	Feeds     FeedStore
+	Items     ItemStore
+	Followers FollowerStore
+}
+
+// UserStore persists users
+type UserStore interface {
+	Create(ctx context.Context, user models.User) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.User, error)
+	GetByFirebaseUID(ctx context.Context, uid string) (models.User, error)
+	// Update overwrites the stored user's fields with those set on user
+	Update(ctx context.Context, id primitive.ObjectID, user models.User) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// Feed health filters accepted by FeedStore.List
+const (
+	HealthAny       = ""
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+	HealthPaused    = "paused"
+)
+
+// FeedFilter narrows FeedStore.List
+type FeedFilter struct {
+	Health string // one of the Health* constants
+}
+
+// FetchSuccess is what a successful fetch records on its feed
+type FetchSuccess struct {
+	At           time.Time
+	HTTPStatus   int
+	ETag         string
+	LastModified string
+	// Hints replaces the stored polling hints; nil keeps them, as a 304
+	// carries none
+	Hints *models.PollingHints
+}
+
+// FetchFailure is what a failed fetch records on its feed
+type FetchFailure struct {
+	At         time.Time
+	Error      string
+	ErrorCode  string
+	HTTPStatus int // zero clears the last HTTP status
+}
+
+// FeedSettings are the fields of a feed its owner edits. Update writes all
+// of them, so a zero PollInterval or nil Retention clears the setting.
+type FeedSettings struct {
+	Name         string
+	Url          string
+	UserID       primitive.ObjectID
+	PollInterval int
+	Retention    *models.RetentionPolicy
+	UpdatedAt    time.Time
+	// NextFetchAt reschedules the feed; nil keeps its schedule
+	NextFetchAt *time.Time
+	// ResetFetchState forgets the validators, polling hints and fetch
+	// health learned from the feed's previous URL, unpausing it
+	ResetFetchState bool
+}
+
+// FeedStore persists feeds, their fetch state and their scrape history
+type FeedStore interface {
+	Create(ctx context.Context, feed models.Feed) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Feed, error)
+	// Update replaces the stored feed's settings, leaving its schedule,
+	// validators and fetch health to the scraper
+	Update(ctx context.Context, id primitive.ObjectID, settings FeedSettings) error
+	// Delete removes the feed and its run history, followers and jobs but
+	// not its items, which are removed with ItemStore.DeleteByFeed so
+	// starred ones are kept
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	List(ctx context.Context, filter FeedFilter) ([]models.Feed, error)
+
+	// RecordFetchSuccess stores the outcome of a successful fetch and
+	// clears any error or pause left by earlier failures
+	RecordFetchSuccess(ctx context.Context, id primitive.ObjectID, success FetchSuccess) error
+	// RecordFetchFailure stores why a fetch failed, counts it and returns
+	// the feed as updated
+	RecordFetchFailure(ctx context.Context, id primitive.ObjectID, failure FetchFailure) (models.Feed, error)
+	Pause(ctx context.Context, id primitive.ObjectID, at time.Time) error
+
+	// Due returns the unpaused feeds never fetched or due by now, soonest first
+	Due(ctx context.Context, now time.Time) ([]models.Feed, error)
+	// NextDue returns the earliest next fetch time of an unpaused feed,
+	// or nil if none is scheduled
+	NextDue(ctx context.Context) (*time.Time, error)
+	// Reschedule records the interval in use, in seconds, and the next fetch time
+	Reschedule(ctx context.Context, id primitive.ObjectID, interval int, next time.Time) error
+
+	RecordRun(ctx context.Context, run models.ScrapeRun) error
+	// ListRuns pages through a feed's runs, newest first, and returns the total
+	ListRuns(ctx context.Context, feedID primitive.ObjectID, limit, offset int64) ([]models.ScrapeRun, int64, error)
+}
+
+// ItemStore persists feed items and their revision history
+type ItemStore interface {
+	// InsertNew stores the items not already stored under their identity
+	// key, or under their link before the feed sent GUIDs, and returns the
+	// ones it stored. Concurrent calls never store an item twice.
+	InsertNew(ctx context.Context, items []models.FeedItem) ([]models.FeedItem, error)
+	// FindByKeys returns the feed's items stored under any of the keys
+	FindByKeys(ctx context.Context, feedID primitive.ObjectID, keys []string) ([]models.FeedItem, error)
+	// ApplyEdit replaces previous's content with updated's and stores
+	// revision, unless previous has changed since it was read. It reports
+	// whether the edit was applied.
+	ApplyEdit(ctx context.Context, previous, updated models.FeedItem, revision models.ItemRevision) (bool, error)
+
+	Get(ctx context.Context, id primitive.ObjectID) (models.FeedItem, error)
+	ListByFeed(ctx context.Context, feedID primitive.ObjectID) ([]models.FeedItem, error)
+	// Revisions returns an item's earlier versions, newest first
+	Revisions(ctx context.Context, itemID primitive.ObjectID) ([]models.ItemRevision, error)
+	SetStarred(ctx context.Context, id primitive.ObjectID, starred bool) error
+	// Search returns up to limit items whose title or description matches
+	// every word of query, best match first. A non-zero feedID limits the
+	// search to that feed.
+	Search(ctx context.Context, query string, feedID primitive.ObjectID, limit int64) ([]models.FeedItem, error)
+
+	// PrunableByAge returns the feed's unstarred items published before cutoff
+	PrunableByAge(ctx context.Context, feedID primitive.ObjectID, cutoff time.Time) ([]primitive.ObjectID, error)
+	// PrunableByCount returns the feed's unstarred items beyond the newest keep
+	PrunableByCount(ctx context.Context, feedID primitive.ObjectID, keep int) ([]primitive.ObjectID, error)
+	// FeedIDs returns every feed ID items are stored under
+	FeedIDs(ctx context.Context) ([]primitive.ObjectID, error)
+	// CountByFeeds counts the unstarred items of the given feeds, the ones
+	// DeleteByFeed would remove
+	CountByFeeds(ctx context.Context, feedIDs []primitive.ObjectID) (int64, error)
+	// Delete removes the given unstarred items and their revisions
+	Delete(ctx context.Context, ids []primitive.ObjectID) (int64, error)
+	// DeleteByFeed removes the unstarred items of a feed and their revisions
+	DeleteByFeed(ctx context.Context, feedID primitive.ObjectID) (int64, error)
+}
+
+// FollowerStore persists users' subscriptions to feeds
+type FollowerStore interface {
+	// Follow stores a subscription, or returns ErrDuplicate if the user
+	// already follows the feed
+	Follow(ctx context.Context, follower models.FeedFollower) error
+	// Unfollow removes a subscription, or returns ErrNotFound
+	Unfollow(ctx context.Context, feedID primitive.ObjectID, userID string) error
+	ListByUser(ctx context.Context, userID string) ([]models.FeedFollower, error)
+	ListByFeed(ctx context.Context, feedID primitive.ObjectID) ([]models.FeedFollower, error)
+	DeleteByUser(ctx context.Context, userID string) error
+	DeleteByFeed(ctx context.Context, feedID primitive.ObjectID) error
+}